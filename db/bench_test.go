@@ -10,13 +10,18 @@ import (
 
 	"github.com/y1w5/stream/db/decoder"
 	decoderv2 "github.com/y1w5/stream/db/decoder/v2"
+	"github.com/y1w5/stream/db/synth"
 )
 
+// benchPages is the number of pages of the synthetic dataset.
+const benchPages = 2000
+
+// loadDatasetInMemory loads the first Wikipedia dataset. It falls back on a
+// synthetic dataset when the Wikipedia dataset is not downloaded.
 var loadDatasetInMemory = sync.OnceValues(func() ([]byte, error) {
-	var err error
-	dataset, err := loadDataset()
+	dataset, err := loadBenchDataset()
 	if err != nil {
-		return nil, fmt.Errorf("fail to load dataset: %w", err)
+		return nil, err
 	}
 
 	buf, err := io.ReadAll(dataset)
@@ -27,11 +32,25 @@ var loadDatasetInMemory = sync.OnceValues(func() ([]byte, error) {
 	return buf, nil
 })
 
-func BenchmarkDecoder(b *testing.B) {
-	dataset, err := loadDatasetInMemory()
+func loadBenchDataset() (io.Reader, error) {
+	dataset, err := loadDataset(datasets[0])
 	if errors.Is(err, ErrDatasetNotFound) {
-		b.SkipNow()
+		var buf bytes.Buffer
+		opts := synth.DefaultOptions()
+		opts.Pages = benchPages
+		if err := synth.WriteXML(&buf, opts); err != nil {
+			return nil, fmt.Errorf("fail to generate dataset: %w", err)
+		}
+		return &buf, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to load dataset: %w", err)
+	}
+	return dataset, nil
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	dataset, err := loadDatasetInMemory()
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -46,8 +65,15 @@ func BenchmarkDecoder(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		var p decoder.Page
 
-		if !d.Next() {
-			b.Fatalf("fail to read next page: %v", err)
+		if !d.Next() && errors.Is(d.Err(), io.EOF) {
+			// The synthetic dataset is smaller than b.N, we rewind it.
+			b.StopTimer()
+			d, _ = decoder.New(bytes.NewReader(dataset))
+			b.StartTimer()
+			d.Next()
+		}
+		if d.Err() != nil {
+			b.Fatalf("fail to read next page: %v", d.Err())
 		}
 
 		err := d.Scan(&p)
@@ -59,9 +85,6 @@ func BenchmarkDecoder(b *testing.B) {
 
 func BenchmarkDecoderV2(b *testing.B) {
 	dataset, err := loadDatasetInMemory()
-	if errors.Is(err, ErrDatasetNotFound) {
-		b.SkipNow()
-	}
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -76,10 +99,15 @@ func BenchmarkDecoderV2(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		var p decoderv2.Page
 
-		if !decoder.Next() {
-			if err != nil {
-				b.Fatalf("fail to read next page: %v", err)
-			}
+		if !decoder.Next() && errors.Is(decoder.Err(), io.EOF) {
+			// The synthetic dataset is smaller than b.N, we rewind it.
+			b.StopTimer()
+			decoder, _ = decoderv2.New(bytes.NewReader(dataset))
+			b.StartTimer()
+			decoder.Next()
+		}
+		if decoder.Err() != nil {
+			b.Fatalf("fail to read next page: %v", decoder.Err())
 		}
 
 		err := decoder.Scan(&p)
@@ -90,10 +118,7 @@ func BenchmarkDecoderV2(b *testing.B) {
 }
 
 func BenchmarkDecoder_streaming(b *testing.B) {
-	dataset, err := loadDataset()
-	if errors.Is(err, ErrDatasetNotFound) {
-		b.SkipNow()
-	}
+	dataset, err := loadBenchDataset()
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -108,8 +133,16 @@ func BenchmarkDecoder_streaming(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		var p decoder.Page
 
-		if !d.Next() {
-			b.Fatalf("fail to read next page: %v", err)
+		if !d.Next() && errors.Is(d.Err(), io.EOF) {
+			// The synthetic dataset is smaller than b.N, we rewind it.
+			b.StopTimer()
+			dataset, _ = loadBenchDataset()
+			d, _ = decoder.New(dataset)
+			b.StartTimer()
+			d.Next()
+		}
+		if d.Err() != nil {
+			b.Fatalf("fail to read next page: %v", d.Err())
 		}
 
 		err := d.Scan(&p)
@@ -121,9 +154,6 @@ func BenchmarkDecoder_streaming(b *testing.B) {
 
 func BenchmarkSummarize(b *testing.B) {
 	dataset, err := loadDatasetInMemory()
-	if errors.Is(err, ErrDatasetNotFound) {
-		b.SkipNow()
-	}
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -138,8 +168,15 @@ func BenchmarkSummarize(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		var p decoder.Page
 
-		if !d.Next() {
-			b.Fatalf("fail to read next page: %v", err)
+		if !d.Next() && errors.Is(d.Err(), io.EOF) {
+			// The synthetic dataset is smaller than b.N, we rewind it.
+			b.StopTimer()
+			d, _ = decoder.New(bytes.NewReader(dataset))
+			b.StartTimer()
+			d.Next()
+		}
+		if d.Err() != nil {
+			b.Fatalf("fail to read next page: %v", d.Err())
 		}
 
 		err := d.Scan(&p)
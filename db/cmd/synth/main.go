@@ -0,0 +1,91 @@
+// Synth generates a synthetic SQLite database or MediaWiki XML dump.
+//
+//	go run ./cmd/synth -pages 10000 -seed 42 -out stream.db
+//	go run ./cmd/synth -pages 10000 -seed 42 -out dump.xml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/y1w5/stream/db/synth"
+)
+
+var featureNames = map[string]synth.Feature{
+	"headings":   synth.Headings,
+	"links":      synth.Links,
+	"templates":  synth.Templates,
+	"refs":       synth.Refs,
+	"formatting": synth.Formatting,
+	"lists":      synth.Lists,
+	"unicode":    synth.Unicode,
+}
+
+func main() {
+	opts := synth.DefaultOptions()
+	var out, features string
+	flag.StringVar(&out, "out", "stream.db", "output file, .xml writes a MediaWiki dump, anything else a SQLite database")
+	flag.Int64Var(&opts.Seed, "seed", opts.Seed, "seed of the generator")
+	flag.IntVar(&opts.Pages, "pages", opts.Pages, "number of pages")
+	flag.IntVar(&opts.MinTextSize, "min-text", opts.MinTextSize, "minimum text size in bytes")
+	flag.IntVar(&opts.MaxTextSize, "max-text", opts.MaxTextSize, "maximum text size in bytes")
+	flag.StringVar(&features, "features", "all", "comma separated list of wikitext features: all, none, headings, links, templates, refs, formatting, lists, unicode")
+	flag.Parse()
+
+	var err error
+	opts.Features, err = parseFeatures(features)
+	if err != nil {
+		fatalf("%v\n", err)
+	}
+
+	if filepath.Ext(out) == ".xml" {
+		err = writeXML(out, opts)
+	} else {
+		err = synth.WriteDB(out, opts)
+	}
+	if err != nil {
+		fatalf("fail to generate %v: %v\n", out, err)
+	}
+
+	fmt.Printf("Completed, %d pages created in %v.\n", opts.Pages, out)
+}
+
+func writeXML(path string, opts synth.Options) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := synth.WriteXML(f, opts); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func parseFeatures(s string) (synth.Feature, error) {
+	switch s {
+	case "all":
+		return synth.AllFeatures, nil
+	case "none", "":
+		return 0, nil
+	}
+
+	var features synth.Feature
+	for _, name := range strings.Split(s, ",") {
+		f, ok := featureNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown feature: %q", name)
+		}
+		features |= f
+	}
+	return features, nil
+}
+
+func fatalf(format string, v ...any) {
+	fmt.Printf(format, v...)
+	os.Exit(1)
+}
@@ -11,6 +11,7 @@ import (
 
 const (
 	dbName   = "./stream.db"
+	dbTmp    = "./stream.db.tmp"
 	dbSchema = "./schema.sql"
 )
 
@@ -36,13 +37,16 @@ type DB struct {
 
 // newDB instanciates a new database.
 //
-// It drops existing SQLite database and recreate a new one from scratch.
+// It creates the SQLite database from scratch next to the existing one,
+// [DB.Publish] replaces the existing database once it is complete. A server
+// serving the existing database keeps its streams running and can reload the
+// new one.
 func newDB() (*DB, error) {
-	os.Remove(dbName)
+	os.Remove(dbTmp)
 
-	db, err := sql.Open("sqlite3", dbName)
+	db, err := sql.Open("sqlite3", dbTmp)
 	if err != nil {
-		return nil, fmt.Errorf("open %v: %v", dbName, err)
+		return nil, fmt.Errorf("open %v: %v", dbTmp, err)
 	}
 
 	err = migrateUp(db)
@@ -126,6 +130,18 @@ func (db *DB) Close() error {
 	return db.db.Close()
 }
 
+// Publish closes the database and atomically replaces the existing database
+// with it.
+func (db *DB) Publish() error {
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("close: %v", err)
+	}
+	if err := os.Rename(dbTmp, dbName); err != nil {
+		return fmt.Errorf("rename: %v", err)
+	}
+	return nil
+}
+
 func migrateUp(db *sql.DB) error {
 	schema, err := os.ReadFile(dbSchema)
 	if err != nil {
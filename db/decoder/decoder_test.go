@@ -1,6 +1,7 @@
 package decoder
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"strings"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	decoderv2 "github.com/y1w5/stream/db/decoder/v2"
+	"github.com/y1w5/stream/db/synth"
 )
 
 var xmlSample = `<mediawiki xmlns="http://www.mediawiki.org/xml/export-0.10/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.mediawiki.org/xml/export-0.10/ http://www.mediawiki.org/xml/export-0.10.xsd" version="0.10" xml:lang="en">
@@ -195,6 +197,46 @@ func TestDecoderV2(t *testing.T) {
 	}
 }
 
+func TestDecoderSynth(t *testing.T) {
+	opts := synth.DefaultOptions()
+	opts.Pages = 50
+
+	var buf bytes.Buffer
+	if err := synth.WriteXML(&buf, opts); err != nil {
+		t.Fatalf("write xml: %v", err)
+	}
+
+	d, err := New(&buf)
+	if err != nil {
+		t.Fatalf("new decoder: %v", err)
+	}
+
+	var pages []synth.Page
+	for p := range synth.Pages(opts) {
+		pages = append(pages, p)
+	}
+	var i int
+	for ; d.Next(); i++ {
+		if i >= len(pages) {
+			t.Fatalf("unexpected page %d", i)
+		}
+		var p Page
+		if err := d.Scan(&p); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		want := pages[i]
+		if p.Title != want.Title || p.Text != want.Text || !p.UpdatedAt.Equal(want.UpdatedAt) {
+			t.Fatalf("page %d: unexpected page: expects=%+v got=%+v", want.ID, want, p)
+		}
+	}
+	if err := d.Err(); err != nil && !errors.Is(err, io.EOF) {
+		t.Fatalf("next: %v", err)
+	}
+	if i != len(pages) {
+		t.Fatalf("unexpected page count: expects=%d got=%d", len(pages), i)
+	}
+}
+
 func mustParseTime(layout, value string) time.Time {
 	t, err := time.Parse(layout, value)
 	if err != nil {
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/y1w5/stream/db/export"
+)
+
+// exportSliceSize is the number of pages of a Parquet row group. It matches
+// the slice size of the server.
+const exportSliceSize = 1 << 16
+
+// runExport exports the pages of the database:
+//
+//	db export [-format parquet] [-db stream.db] [-out pages.parquet]
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "parquet", "output format: parquet")
+	in := fs.String("db", dbName, "path to the SQLite database")
+	out := fs.String("out", "pages.parquet", "output file")
+	_ = fs.Parse(args)
+
+	if *format != "parquet" {
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+*in+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("open %v: %v", *in, err)
+	}
+	defer db.Close()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("create %v: %v", *out, err)
+	}
+	defer f.Close()
+
+	buf := bufio.NewWriter(f)
+	w, err := export.NewParquetWriter(buf, export.DefaultParquetOptions())
+	if err != nil {
+		return fmt.Errorf("new parquet writer: %v", err)
+	}
+
+	bar := spinner.Start(0).Set("prefix", "  "+*out)
+	for pages, err := range pageSlices(db, exportSliceSize) {
+		if err != nil {
+			return err
+		}
+		if err := w.Write(pages); err != nil {
+			return fmt.Errorf("write: %v", err)
+		}
+		bar.Add(len(pages))
+	}
+	bar.Finish()
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("close parquet writer: %v", err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("flush: %v", err)
+	}
+	return f.Close()
+}
+
+// pageSlices streams the pages ordered by ID as slices of size pages. The
+// slice is reused between iterations.
+func pageSlices(db *sql.DB, size int) func(func([]export.Page, error) bool) {
+	return func(yield func([]export.Page, error) bool) {
+		rows, err := db.Query(`SELECT id, updated_at, title, text FROM pages ORDER BY id`)
+		if err != nil {
+			yield(nil, fmt.Errorf("query: %v", err))
+			return
+		}
+		defer rows.Close()
+
+		pages := make([]export.Page, 0, size)
+		for rows.Next() {
+			var p export.Page
+			if err := rows.Scan(&p.ID, &p.UpdatedAt, &p.Title, &p.Text); err != nil {
+				yield(nil, fmt.Errorf("scan: %v", err))
+				return
+			}
+			pages = append(pages, p)
+			if len(pages) < size {
+				continue
+			}
+			if !yield(pages, nil) {
+				return
+			}
+			pages = pages[:0]
+		}
+		if err := rows.Err(); err != nil {
+			yield(nil, fmt.Errorf("next: %v", err))
+			return
+		}
+		if len(pages) > 0 {
+			yield(pages, nil)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package export writes pages in file formats used for offline analysis.
+package export
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// Page is a page written by the exporters.
+type Page struct {
+	ID        int64
+	UpdatedAt time.Time
+	Title     string
+	Text      string
+}
+
+// Schema is the Arrow schema of the exported pages.
+var Schema = arrow.NewSchema([]arrow.Field{
+	{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "updated_at", Type: &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}},
+	{Name: "title", Type: arrow.BinaryTypes.String},
+	{Name: "text", Type: arrow.BinaryTypes.String},
+}, nil)
+
+// ParquetOptions configures a [ParquetWriter].
+type ParquetOptions struct {
+	// Compression maps the column names to their codec: uncompressed,
+	// snappy, gzip, brotli, zstd or lz4_raw.
+	Compression map[string]string
+}
+
+// DefaultParquetOptions returns the options used by the endpoints and the
+// command-line tools. The text columns are compressed with zstd, the others
+// with snappy.
+func DefaultParquetOptions() ParquetOptions {
+	return ParquetOptions{
+		Compression: map[string]string{
+			"id":         "snappy",
+			"updated_at": "snappy",
+			"title":      "zstd",
+			"text":       "zstd",
+		},
+	}
+}
+
+// ParquetWriter streams pages into a Parquet file. Each call to
+// [ParquetWriter.Write] writes a row group, the file is complete once
+// [ParquetWriter.Close] returns.
+type ParquetWriter struct {
+	fw *pqarrow.FileWriter
+	b  *array.RecordBuilder
+}
+
+// NewParquetWriter creates a [ParquetWriter] writing into w.
+func NewParquetWriter(w io.Writer, opts ParquetOptions) (*ParquetWriter, error) {
+	mem := memory.NewGoAllocator()
+	props := []parquet.WriterProperty{
+		parquet.WithAllocator(mem),
+		// The IDs and texts are unique, a dictionary only costs memory.
+		parquet.WithDictionaryFor("id", false),
+		parquet.WithDictionaryFor("text", false),
+	}
+	for _, name := range slices.Sorted(maps.Keys(opts.Compression)) {
+		if _, ok := Schema.FieldsByName(name); !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		var codec compress.Compression
+		if err := codec.UnmarshalText([]byte(strings.ToUpper(opts.Compression[name]))); err != nil {
+			return nil, fmt.Errorf("column %q: %v", name, err)
+		}
+		props = append(props, parquet.WithCompressionFor(name, codec))
+	}
+
+	// The Parquet writer closes writers implementing io.Closer, hide it.
+	fw, err := pqarrow.NewFileWriter(Schema, struct{ io.Writer }{w}, parquet.NewWriterProperties(props...), pqarrow.NewArrowWriterProperties(pqarrow.WithAllocator(mem)))
+	if err != nil {
+		return nil, fmt.Errorf("new file writer: %v", err)
+	}
+	return &ParquetWriter{fw: fw, b: array.NewRecordBuilder(mem, Schema)}, nil
+}
+
+// Write writes pages as a row group.
+func (w *ParquetWriter) Write(pages []Page) error {
+	rec := NewRecord(w.b, pages)
+	defer rec.Release()
+	return w.fw.Write(rec)
+}
+
+// Close writes the footer of the file. It does not close the underlying
+// writer.
+func (w *ParquetWriter) Close() error {
+	defer w.b.Release()
+	return w.fw.Close()
+}
+
+// NewRecord builds a record batch of [Schema] from pages with b.
+func NewRecord(b *array.RecordBuilder, pages []Page) arrow.RecordBatch {
+	b.Reserve(len(pages))
+	ids := b.Field(0).(*array.Int64Builder)
+	updatedAt := b.Field(1).(*array.TimestampBuilder)
+	titles := b.Field(2).(*array.StringBuilder)
+	texts := b.Field(3).(*array.StringBuilder)
+
+	for _, p := range pages {
+		ids.Append(p.ID)
+		updatedAt.Append(arrow.Timestamp(p.UpdatedAt.UnixNano()))
+		titles.Append(p.Title)
+		texts.Append(p.Text)
+	}
+	return b.NewRecordBatch()
+}
@@ -0,0 +1,88 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+func TestParquetWriter(t *testing.T) {
+	start := time.Date(2023, 10, 20, 10, 0, 0, 0, time.UTC)
+	var pages []Page
+	for i := range 5 {
+		pages = append(pages, Page{ID: int64(i + 1), UpdatedAt: start.Add(time.Duration(i) * time.Hour), Title: "Page", Text: "a\nb"})
+	}
+
+	var buf bytes.Buffer
+	w, err := NewParquetWriter(&buf, DefaultParquetOptions())
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	for _, batch := range [][]Page{pages[:3], pages[3:]} {
+		if err := w.Write(batch); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	r, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+	defer r.Close()
+	if r.NumRowGroups() != 2 || r.NumRows() != 5 {
+		t.Fatalf("unexpected layout: row groups=%d rows=%d", r.NumRowGroups(), r.NumRows())
+	}
+	meta := r.MetaData().RowGroup(0)
+	for i, want := range []compress.Compression{compress.Codecs.Snappy, compress.Codecs.Snappy, compress.Codecs.Zstd, compress.Codecs.Zstd} {
+		col, err := meta.ColumnChunk(i)
+		if err != nil {
+			t.Fatalf("column %d: %v", i, err)
+		}
+		if col.Compression() != want {
+			t.Fatalf("column %d: unexpected compression: expects=%v got=%v", i, want, col.Compression())
+		}
+	}
+
+	fr, err := pqarrow.NewFileReader(r, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatalf("new file reader: %v", err)
+	}
+	tbl, err := fr.ReadTable(context.Background())
+	if err != nil {
+		t.Fatalf("read table: %v", err)
+	}
+	defer tbl.Release()
+
+	ids := tbl.Column(0).Data().Chunk(0).(*array.Int64)
+	updatedAt := tbl.Column(1).Data().Chunk(0).(*array.Timestamp)
+	if ids.Value(4) != 5 || !updatedAt.Value(4).ToTime(arrow.Nanosecond).Equal(pages[4].UpdatedAt) {
+		t.Fatalf("unexpected last page: id=%d updated_at=%v", ids.Value(4), updatedAt.Value(4))
+	}
+}
+
+func TestParquetOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ParquetOptions
+	}{
+		{name: "column", opts: ParquetOptions{Compression: map[string]string{"missing": "zstd"}}},
+		{name: "codec", opts: ParquetOptions{Compression: map[string]string{"text": "lzo2"}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewParquetWriter(&bytes.Buffer{}, tt.opts); err == nil {
+			t.Fatalf("%v: expects error", tt.name)
+		}
+	}
+}
@@ -16,6 +16,13 @@ var spinner pb.ProgressBarTemplate = `{{with string . "prefix"}}{{.}} {{end}} {{
 var spinnerETA pb.ProgressBarTemplate = `{{with string . "prefix"}}{{.}} {{end}} {{ cycle . "⠋" "⠙" "⠹" "⠸" "⠼" "⠴" "⠦" "⠧" "⠇" "⠏" }} {{counters .}} {{speed . "%s p/s"}} {{rtime .}}{{with string . "suffix"}} {{.}}{{end}}`
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			fatalf("fail to export: %v\n", err)
+		}
+		return
+	}
+
 	fmt.Printf("Loading Wikipedia dataset...\n")
 	datasets, err := loadDatasets()
 	if err != nil {
@@ -42,6 +49,9 @@ func main() {
 		bar.Finish()
 	}
 
+	if err := db.Publish(); err != nil {
+		fatalf("fail to publish db: %v", err)
+	}
 	fmt.Printf("Completed, %d pages created.\n", count)
 }
 
@@ -0,0 +1,76 @@
+package synth
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// schema mirrors schema.sql from the db program.
+const schema = `CREATE TABLE pages (
+    id         INTEGER PRIMARY KEY,
+    updated_at TIMESTAMP NOT NULL,
+    title      TEXT NOT NULL,
+    "text"     TEXT NOT NULL
+);`
+
+var createPageQuery = `INSERT INTO pages (id, updated_at, title, "text") VALUES (?, ?, ?, ?)`
+
+// WriteDB writes the pages described by opts into a new SQLite database at
+// path. An existing file is replaced.
+func WriteDB(path string, opts Options) error {
+	// Write next to path and rename so that a server serving path never
+	// opens a partial database.
+	tmp := path + ".tmp"
+	if err := writeDB(tmp, opts); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename %v: %v", tmp, err)
+	}
+	return nil
+}
+
+func writeDB(path string, opts Options) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove %v: %v", path, err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("open %v: %v", path, err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(schema); err != nil {
+		return fmt.Errorf("migrate up: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %v", err)
+	}
+	defer tx.Rollback() //nolint
+
+	stmt, err := tx.Prepare(createPageQuery)
+	if err != nil {
+		return fmt.Errorf("prepare: %v", err)
+	}
+	defer stmt.Close()
+
+	for p := range Pages(opts) {
+		_, err := stmt.Exec(p.ID, p.UpdatedAt.Format(time.DateTime), p.Title, p.Text)
+		if err != nil {
+			return fmt.Errorf("create page %d: %v", p.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %v", err)
+	}
+	return db.Close()
+}
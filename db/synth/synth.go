@@ -0,0 +1,233 @@
+// Package synth generates synthetic Wikipedia pages for tests and benchmarks.
+//
+// The pages are generated from a seed: two calls with the same [Options]
+// return the same pages.
+package synth
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Feature is a wikitext feature included in the generated text.
+type Feature uint
+
+const (
+	// Headings adds section titles, e.g. "== History ==".
+	Headings Feature = 1 << iota
+	// Links adds internal links, e.g. "[[Anarchism|anarchist]]".
+	Links
+	// Templates adds nested templates, e.g. "{{Infobox|name={{lang|fr|x}}}}".
+	Templates
+	// Refs adds references, e.g. "<ref>...</ref>".
+	Refs
+	// Formatting adds bold and italic text.
+	Formatting
+	// Lists adds bullet lists.
+	Lists
+	// Unicode adds non-ASCII text and characters escaped by JSON and XML.
+	Unicode
+
+	// AllFeatures enables all the features.
+	AllFeatures = Headings | Links | Templates | Refs | Formatting | Lists | Unicode
+)
+
+// Options stores the parameters of the generator.
+type Options struct {
+	// Seed makes the generation reproducible.
+	Seed int64
+	// Pages is the number of generated pages.
+	Pages int
+	// MinTextSize and MaxTextSize bound the size of the text in bytes. The
+	// text can exceed MaxTextSize by a few words.
+	MinTextSize int
+	MaxTextSize int
+	// Features lists the wikitext features included in the text.
+	Features Feature
+	// Start is the update date of the first page, the following pages are
+	// updated every few hours.
+	Start time.Time
+}
+
+// DefaultOptions returns options generating a small dataset with all the
+// features.
+func DefaultOptions() Options {
+	return Options{
+		Seed:        1,
+		Pages:       1000,
+		MinTextSize: 200,
+		MaxTextSize: 8000,
+		Features:    AllFeatures,
+		Start:       time.Date(2001, time.January, 15, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// Page represents a generated page.
+type Page struct {
+	ID        int64
+	UpdatedAt time.Time
+	Title     string
+	Text      string
+}
+
+// Pages generates the pages described by opts.
+func Pages(opts Options) func(func(Page) bool) {
+	return func(yield func(Page) bool) {
+		g := generator{
+			opts: opts,
+			r:    rand.New(rand.NewSource(opts.Seed)),
+		}
+		updatedAt := opts.Start.UTC().Truncate(time.Second)
+		for i := 0; i < opts.Pages; i++ {
+			updatedAt = updatedAt.Add(time.Duration(1+g.r.Intn(12)) * time.Hour)
+			p := Page{
+				ID:        int64(i + 1),
+				UpdatedAt: updatedAt,
+				Title:     g.title(i + 1),
+				Text:      g.text(),
+			}
+			if !yield(p) {
+				return
+			}
+		}
+	}
+}
+
+var words = strings.Fields(`the of and in to was is for as on by with from that
+at his an which are also first were this or be had their its city new other
+after one two has all who history language people state war world century
+government system early during between group later known many most over part
+political public since time under use used while work theory movement`)
+
+var unicodeWords = strings.Fields(`café Zürich naïve Kraków São_Paulo Ελλάδα
+Москва 東京 서울 القاهرة 🎉 “quoted” a<b Tom&Jerry back\slash "quote"`)
+
+// generator generates pages from a random source.
+type generator struct {
+	opts Options
+	r    *rand.Rand
+	b    strings.Builder
+}
+
+func (g *generator) has(f Feature) bool {
+	return g.opts.Features&f != 0
+}
+
+func (g *generator) word() string {
+	if g.has(Unicode) && g.r.Intn(20) == 0 {
+		return unicodeWords[g.r.Intn(len(unicodeWords))]
+	}
+	return words[g.r.Intn(len(words))]
+}
+
+func (g *generator) title(id int) string {
+	n := 1 + g.r.Intn(3)
+	parts := make([]string, 0, n+1)
+	for i := 0; i < n; i++ {
+		w := g.word()
+		r, size := utf8.DecodeRuneInString(w)
+		parts = append(parts, string(unicode.ToUpper(r))+w[size:])
+	}
+	parts = append(parts, strconv.Itoa(id))
+	return strings.Join(parts, " ")
+}
+
+func (g *generator) text() string {
+	size := g.opts.MinTextSize
+	if g.opts.MaxTextSize > size {
+		size += g.r.Intn(g.opts.MaxTextSize - size + 1)
+	}
+
+	g.b.Reset()
+	if g.has(Templates) {
+		g.template(0)
+		g.b.WriteString("\n")
+	}
+	for g.b.Len() < size {
+		switch {
+		case g.has(Headings) && g.b.Len() > 0 && g.r.Intn(4) == 0:
+			g.b.WriteString("\n== ")
+			g.sentence(1 + g.r.Intn(3))
+			g.b.WriteString(" ==\n")
+		case g.has(Lists) && g.r.Intn(6) == 0:
+			n := 2 + g.r.Intn(4)
+			for i := 0; i < n; i++ {
+				g.b.WriteString("* ")
+				g.sentence(3 + g.r.Intn(8))
+				g.b.WriteString("\n")
+			}
+		default:
+			g.paragraph(size)
+		}
+	}
+	return g.b.String()
+}
+
+// paragraph writes sentences until the text reaches size.
+func (g *generator) paragraph(size int) {
+	n := 2 + g.r.Intn(6)
+	for i := 0; i < n && g.b.Len() < size; i++ {
+		if i > 0 {
+			g.b.WriteString(" ")
+		}
+		g.sentence(5 + g.r.Intn(20))
+		g.b.WriteString(".")
+		if g.has(Refs) && g.r.Intn(5) == 0 {
+			g.b.WriteString("<ref>{{cite web|url=https://example.org/")
+			g.b.WriteString(strconv.Itoa(g.r.Intn(1 << 20)))
+			g.b.WriteString("|title=")
+			g.sentence(3)
+			g.b.WriteString("}}</ref>")
+		}
+	}
+	g.b.WriteString("\n\n")
+}
+
+func (g *generator) sentence(n int) {
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			g.b.WriteString(" ")
+		}
+		w := g.word()
+		switch {
+		case g.has(Links) && g.r.Intn(10) == 0:
+			g.b.WriteString("[[")
+			g.b.WriteString(w)
+			if g.r.Intn(2) == 0 {
+				g.b.WriteString("|")
+				g.b.WriteString(g.word())
+			}
+			g.b.WriteString("]]")
+		case g.has(Formatting) && g.r.Intn(15) == 0:
+			quotes := "''"
+			if g.r.Intn(2) == 0 {
+				quotes = "'''"
+			}
+			g.b.WriteString(quotes + w + quotes)
+		default:
+			g.b.WriteString(w)
+		}
+	}
+}
+
+// template writes a template with nested templates up to 2 levels.
+func (g *generator) template(depth int) {
+	g.b.WriteString("{{Infobox ")
+	g.b.WriteString(g.word())
+	n := 1 + g.r.Intn(4)
+	for i := 0; i < n; i++ {
+		g.b.WriteString("\n| ")
+		g.b.WriteString(g.word())
+		g.b.WriteString(" = ")
+		if depth < 2 && g.r.Intn(3) == 0 {
+			g.template(depth + 1)
+			continue
+		}
+		g.sentence(1 + g.r.Intn(4))
+	}
+	g.b.WriteString("\n}}")
+}
@@ -0,0 +1,74 @@
+package synth
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func testOptions() Options {
+	opts := DefaultOptions()
+	opts.Pages = 50
+	return opts
+}
+
+func collect(opts Options) []Page {
+	var pages []Page
+	for p := range Pages(opts) {
+		pages = append(pages, p)
+	}
+	return pages
+}
+
+func TestPages(t *testing.T) {
+	opts := testOptions()
+
+	pages := collect(opts)
+	if len(pages) != opts.Pages {
+		t.Fatalf("unexpected page count: expects=%d got=%d", opts.Pages, len(pages))
+	}
+	for i, p := range pages {
+		if p.ID != int64(i+1) {
+			t.Fatalf("unexpected id: expects=%d got=%d", i+1, p.ID)
+		}
+		if len(p.Text) < opts.MinTextSize {
+			t.Fatalf("page %d: text too short: %d", p.ID, len(p.Text))
+		}
+	}
+
+	again := collect(opts)
+	for i := range pages {
+		if pages[i] != again[i] {
+			t.Fatalf("page %d: generation is not reproducible", pages[i].ID)
+		}
+	}
+
+	opts.Seed++
+	other := collect(opts)
+	if pages[0].Text == other[0].Text {
+		t.Fatalf("different seeds generated the same text")
+	}
+}
+
+func TestWriteDB(t *testing.T) {
+	opts := testOptions()
+	path := filepath.Join(t.TempDir(), "stream.db")
+
+	if err := WriteDB(path, opts); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow(`SELECT count(*) FROM pages`).Scan(&count); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != opts.Pages {
+		t.Fatalf("unexpected page count: expects=%d got=%d", opts.Pages, count)
+	}
+}
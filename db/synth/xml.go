@@ -0,0 +1,50 @@
+package synth
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const xmlHeader = `<mediawiki xmlns="http://www.mediawiki.org/xml/export-0.10/" version="0.10" xml:lang="en">
+  <siteinfo>
+    <sitename>Synthetic Wikipedia</sitename>
+    <dbname>synthwiki</dbname>
+    <generator>github.com/y1w5/stream/db/synth</generator>
+    <case>first-letter</case>
+  </siteinfo>
+`
+
+const xmlFooter = "</mediawiki>\n"
+
+// WriteXML writes the pages described by opts as a MediaWiki XML dump.
+func WriteXML(w io.Writer, opts Options) error {
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString(xmlHeader)
+	for p := range Pages(opts) {
+		writeXMLPage(bw, p)
+	}
+	_, _ = bw.WriteString(xmlFooter)
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write: %v", err)
+	}
+	return nil
+}
+
+func writeXMLPage(w *bufio.Writer, p Page) {
+	id := strconv.FormatInt(p.ID, 10)
+
+	_, _ = w.WriteString("  <page>\n    <title>")
+	_ = xml.EscapeText(w, []byte(p.Title))
+	_, _ = w.WriteString("</title>\n    <ns>0</ns>\n    <id>" + id + "</id>\n")
+	_, _ = w.WriteString("    <revision>\n      <id>" + id + "</id>\n")
+	_, _ = w.WriteString("      <timestamp>" + p.UpdatedAt.Format(time.RFC3339) + "</timestamp>\n")
+	_, _ = w.WriteString("      <model>wikitext</model>\n      <format>text/x-wiki</format>\n")
+	_, _ = w.WriteString(`      <text bytes="` + strconv.Itoa(len(p.Text)) + `" xml:space="preserve">`)
+	_ = xml.EscapeText(w, []byte(p.Text))
+	_, _ = w.WriteString("</text>\n    </revision>\n  </page>\n")
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+)
+
+// streamRegistry tracks the in-flight page requests so operators can inspect
+// and cancel them from the admin listener.
+type streamRegistry struct {
+	mu      sync.Mutex
+	nextID  int64
+	streams map[int64]*streamInfo
+}
+
+// streamInfo stores the progress of an in-flight request.
+type streamInfo struct {
+	id     int64
+	route  string
+	client string
+	start  time.Time
+	cancel context.CancelFunc
+
+	pages atomic.Int64
+	bytes atomic.Int64
+
+	// memory is the approximate memory held by the request, budget is its
+	// maximum, 0 disables it.
+	memory     atomic.Int64
+	peakMemory atomic.Int64
+	budget     int64
+}
+
+// add registers a request with a memory budget, cancel cancels its context.
+func (reg *streamRegistry) add(route, client string, budget int64, cancel context.CancelFunc) *streamInfo {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.streams == nil {
+		reg.streams = make(map[int64]*streamInfo)
+	}
+	reg.nextID++
+	info := &streamInfo{
+		id:     reg.nextID,
+		route:  route,
+		client: client,
+		start:  time.Now(),
+		cancel: cancel,
+		budget: budget,
+	}
+	reg.streams[info.id] = info
+	return info
+}
+
+func (reg *streamRegistry) remove(id int64) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.streams, id)
+}
+
+// get returns the request with the given id or nil.
+func (reg *streamRegistry) get(id int64) *streamInfo {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.streams[id]
+}
+
+// list returns the requests ordered by id.
+func (reg *streamRegistry) list() []*streamInfo {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	streams := make([]*streamInfo, 0, len(reg.streams))
+	for _, info := range reg.streams {
+		streams = append(streams, info)
+	}
+	slices.SortFunc(streams, func(a, b *streamInfo) int { return cmp.Compare(a.id, b.id) })
+	return streams
+}
+
+type streamInfoKey struct{}
+
+// streamFromContext returns the request registered in ctx or nil.
+func streamFromContext(ctx context.Context) *streamInfo {
+	info, _ := ctx.Value(streamInfoKey{}).(*streamInfo)
+	return info
+}
+
+// addPages adds n pages to the count of the request, info may be nil.
+func (info *streamInfo) addPages(n int) {
+	if info != nil {
+		info.pages.Add(int64(n))
+	}
+}
+
+// countPages counts the pages read from pages in the request of ctx.
+func countPages(ctx context.Context, pages func(func(Page, error) bool)) func(func(Page, error) bool) {
+	info := streamFromContext(ctx)
+	if info == nil {
+		return pages
+	}
+	return func(yield func(Page, error) bool) {
+		for p, err := range pages {
+			if err == nil {
+				info.pages.Add(1)
+			}
+			if !yield(p, err) {
+				return
+			}
+		}
+	}
+}
+
+// trackedWriter counts the bytes written in the response of a request.
+type trackedWriter struct {
+	http.ResponseWriter
+	info *streamInfo
+}
+
+// Write implements [io.Writer].
+func (w *trackedWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.info.bytes.Add(int64(n))
+	return n, err
+}
+
+// Unwrap returns the underlying response writer, it is used by
+// [http.ResponseController].
+func (w *trackedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// track registers the request for the lifetime of next. The request context
+// is cancelled by /admin/streams/{id}/cancel, which also expires the write
+// deadline to interrupt a write blocked on a stalled client.
+func (s *Stream) track(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		rc := http.NewResponseController(w)
+		info := s.streams.add(r.URL.Path, r.RemoteAddr, s.requestMemory, func() {
+			cancel()
+			_ = rc.SetWriteDeadline(time.Now())
+		})
+		defer s.streams.remove(info.id)
+
+		ctx = context.WithValue(ctx, streamInfoKey{}, info)
+		next(&trackedWriter{ResponseWriter: w, info: info}, r.WithContext(ctx))
+	}
+}
+
+// adminHandler returns the handler of the admin listener.
+func (s *Stream) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", notFoundHandler)
+	mux.HandleFunc("/admin/streams", allowMethods(s.listStreams, http.MethodGet))
+	mux.HandleFunc("/admin/streams/{id}/cancel", allowMethods(s.cancelStream, http.MethodPost))
+	mux.HandleFunc("/admin/db", allowMethods(s.dbStats, http.MethodGet))
+	mux.HandleFunc("/admin/db/reload", allowMethods(s.reloadDB, http.MethodPost))
+	return mux
+}
+
+// streamView is the admin representation of an in-flight request.
+type streamView struct {
+	ID         int64     `json:"id"`
+	Route      string    `json:"route"`
+	Client     string    `json:"client"`
+	Pages      int64     `json:"pages"`
+	Bytes      int64     `json:"bytes"`
+	Memory     int64     `json:"memory"`
+	PeakMemory int64     `json:"peak_memory"`
+	StartedAt  time.Time `json:"started_at"`
+	Age        string    `json:"age"`
+}
+
+type streamsPayload struct {
+	Streams    []streamView `json:"streams"`
+	SlowAborts int64        `json:"slow_aborts"`
+}
+
+func (s *Stream) listStreams(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	payload := streamsPayload{Streams: []streamView{}, SlowAborts: s.slowAborts.Load()}
+	for _, info := range s.streams.list() {
+		payload.Streams = append(payload.Streams, streamView{
+			ID:         info.id,
+			Route:      info.route,
+			Client:     info.client,
+			Pages:      info.pages.Load(),
+			Bytes:      info.bytes.Load(),
+			Memory:     info.memory.Load(),
+			PeakMemory: info.peakMemory.Load(),
+			StartedAt:  info.start,
+			Age:        time.Since(info.start).Round(time.Millisecond).String(),
+		})
+	}
+
+	err := jsonv2.MarshalWrite(w, response{OK: true, Payload: payload})
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+}
+
+func (s *Stream) cancelStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tmp := r.PathValue("id")
+	id, err := strconv.ParseInt(tmp, 10, 64)
+	if err != nil || id < 1 {
+		writeError(w, invalidParameter("id", tmp))
+		return
+	}
+
+	info := s.streams.get(id)
+	if info == nil {
+		writeError(w, &apiError{status: http.StatusNotFound, code: codeNotFound, message: "stream not found"})
+		return
+	}
+	info.cancel()
+	s.logger.Warn("cancel stream", "id", id, "route", info.route, "client", info.client)
+
+	err = jsonv2.MarshalWrite(w, response{OK: true})
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+}
+
+func (s *Stream) dbStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	name := r.URL.Query().Get("collection")
+	c, ok := s.collections[name]
+	if !ok {
+		writeError(w, collectionNotFound(name))
+		return
+	}
+
+	ref := c.acquire()
+	defer c.release(ref)
+	stats, err := ref.db.Stats(r.Context())
+	if err != nil {
+		s.logger.Error("fail to get db stats", "err", err)
+		writeError(w, err)
+		return
+	}
+
+	err = jsonv2.MarshalWrite(w, response{OK: true, Payload: stats})
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+}
+
+// reloadDB reloads the database of the collection query parameter, or of all
+// the collections without it.
+func (s *Stream) reloadDB(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var names []string
+	if r.URL.Query().Has("collection") {
+		names = append(names, r.URL.Query().Get("collection"))
+	}
+
+	err := s.Reload(names...)
+	if err != nil {
+		s.logger.Error("fail to reload database", "err", err)
+		writeError(w, err)
+		return
+	}
+
+	err = jsonv2.MarshalWrite(w, response{OK: true})
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsonv2 "github.com/go-json-experiment/json"
+)
+
+func TestAdminStreams(t *testing.T) {
+	s := newTestStream(t)
+	admin := s.adminHandler()
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+	var ctxErr error
+	h := s.track(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+		streamFromContext(r.Context()).addPages(3)
+		close(started)
+		<-r.Context().Done()
+		ctxErr = r.Context().Err()
+	})
+	go func() {
+		h(httptest.NewRecorder(), httptest.NewRequest("GET", "/pages.list", nil))
+		close(done)
+	}()
+	<-started
+
+	var list struct {
+		Payload streamsPayload `json:"payload"`
+	}
+	resp := httptest.NewRecorder()
+	admin.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/streams", nil))
+	if err := jsonv2.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode streams: %v", err)
+	}
+	if len(list.Payload.Streams) != 1 {
+		t.Fatalf("unexpected stream count: expects=1 got=%d", len(list.Payload.Streams))
+	}
+	got := list.Payload.Streams[0]
+	if got.Route != "/pages.list" || got.Pages != 3 || got.Bytes != 2 {
+		t.Fatalf("unexpected stream: %+v", got)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{method: "GET", path: "/admin/streams/1/cancel", status: http.StatusMethodNotAllowed},
+		{method: "POST", path: "/admin/streams/abc/cancel", status: http.StatusBadRequest},
+		{method: "POST", path: "/admin/streams/42/cancel", status: http.StatusNotFound},
+		{method: "POST", path: "/admin/streams/1/cancel", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp := httptest.NewRecorder()
+		admin.ServeHTTP(resp, httptest.NewRequest(tt.method, tt.path, nil))
+		if resp.Code != tt.status {
+			t.Fatalf("%v %v: unexpected status: expects=%d got=%d", tt.method, tt.path, tt.status, resp.Code)
+		}
+	}
+	<-done
+	if ctxErr == nil {
+		t.Fatalf("expects cancelled context")
+	}
+	if streams := s.streams.list(); len(streams) != 0 {
+		t.Fatalf("unexpected streams after cancel: %d", len(streams))
+	}
+}
+
+func TestAdminDB(t *testing.T) {
+	s := newTestStream(t)
+
+	var stats struct {
+		Payload DBStats `json:"payload"`
+	}
+	resp := httptest.NewRecorder()
+	s.adminHandler().ServeHTTP(resp, httptest.NewRequest("GET", "/admin/db", nil))
+	if err := jsonv2.Unmarshal(resp.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if stats.Payload.Pages != 20 || stats.Payload.FileSize == 0 || stats.Payload.PageSize == 0 || stats.Payload.JournalMode == "" {
+		t.Fatalf("unexpected stats: %+v", stats.Payload)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/y1w5/stream/db/export"
+)
+
+// writeArrow streams pages as an Arrow IPC stream. Each slice of pages is
+// written as a record batch and flushed, the flush policy does not apply.
+func (s *Stream) writeArrow(w http.ResponseWriter, r *http.Request, slices func(func([]Page, error) bool)) {
+	fw := newFlushWriter(w, FlushPolicy{}, s.slowClient)
+	defer s.closeStream(r.Context(), fw)
+	fw.track(streamFromContext(r.Context()))
+
+	w.Header().Set("Content-Type", formatArrow.ContentType())
+	w.Header().Add("Vary", "Accept-Encoding")
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		fw.Gzip()
+	}
+
+	mem := memory.NewGoAllocator()
+	b := array.NewRecordBuilder(mem, export.Schema)
+	defer b.Release()
+
+	iw := ipc.NewWriter(fw, ipc.WithSchema(export.Schema), ipc.WithAllocator(mem))
+	var buf []export.Page
+	info := streamFromContext(r.Context())
+	for pages, err := range slices {
+		if err != nil {
+			s.failStream(r.Context(), "fail to stream pages", err, formatArrow)
+			return
+		}
+
+		buf = exportPages(buf, pages)
+		rec := export.NewRecord(b, buf)
+		err = iw.Write(rec)
+		rec.Release()
+		if err != nil {
+			s.failStream(r.Context(), "fail to encode Arrow", err, formatArrow)
+			return
+		}
+		info.addPages(len(pages))
+
+		err = fw.Flush()
+		if err != nil {
+			s.failStream(r.Context(), "fail to flush response", err, formatArrow)
+			return
+		}
+	}
+
+	// Close writes the end of stream marker, an empty response still holds
+	// the schema.
+	err := iw.Close()
+	if err != nil {
+		s.failStream(r.Context(), "fail to encode Arrow", err, formatArrow)
+		return
+	}
+}
+
+// batchPages groups pages in slices of at most bytes of text or
+// [DBSliceSize] pages. The slice is reused between iterations.
+func batchPages(pages func(func(Page, error) bool), bytes int) func(func([]Page, error) bool) {
+	return func(yield func([]Page, error) bool) {
+		s := newPageSlicer(bytes)
+		defer s.release()
+		for p, err := range pages {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !s.add(p) {
+				continue
+			}
+			if !yield(s.pages(), nil) {
+				return
+			}
+			s.reset()
+		}
+		if len(s.pages()) > 0 {
+			yield(s.pages(), nil)
+		}
+	}
+}
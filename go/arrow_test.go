@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+)
+
+// decodeArrow decodes an Arrow IPC stream into the generic objects returned
+// by [decodePages].
+func decodeArrow(body []byte) ([]map[string]any, error) {
+	r, err := ipc.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Release()
+
+	pages := []map[string]any{}
+	for r.Next() {
+		rec := r.RecordBatch()
+		ids := rec.Column(0).(*array.Int64)
+		updatedAt := rec.Column(1).(*array.Timestamp)
+		titles := rec.Column(2).(*array.String)
+		texts := rec.Column(3).(*array.String)
+		for i := range int(rec.NumRows()) {
+			pages = append(pages, map[string]any{
+				"ID":        float64(ids.Value(i)),
+				"UpdatedAt": updatedAt.Value(i).ToTime(arrow.Nanosecond).Format(time.RFC3339Nano),
+				"Title":     titles.Value(i),
+				"Text":      texts.Value(i),
+			})
+		}
+	}
+	return pages, r.Err()
+}
+
+func TestBatchPages(t *testing.T) {
+	tests := []struct {
+		count int
+		text  string
+		bytes int
+		want  []int
+	}{
+		{count: 0, text: "a", bytes: 2, want: nil},
+		{count: 4, text: "a", bytes: 2, want: []int{2, 2}},
+		{count: 5, text: "a", bytes: 2, want: []int{2, 2, 1}},
+		{count: 3, text: "abc", bytes: 2, want: []int{1, 1, 1}},
+		{count: DBSliceSize + 1, bytes: DBSliceBytes, want: []int{DBSliceSize, 1}},
+	}
+
+	for _, tt := range tests {
+		pages := func(yield func(Page, error) bool) {
+			for i := range tt.count {
+				if !yield(Page{ID: int64(i + 1), Text: tt.text}, nil) {
+					return
+				}
+			}
+		}
+
+		var got []int
+		for batch, err := range batchPages(pages, tt.bytes) {
+			if err != nil {
+				t.Fatalf("batch: %v", err)
+			}
+			got = append(got, len(batch))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Fatalf("%d pages: unexpected batches: expects=%v got=%v", tt.count, tt.want, got)
+		}
+	}
+}
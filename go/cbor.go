@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// CBOR major types.
+const (
+	cborUint  = 0 << 5
+	cborNeg   = 1 << 5
+	cborText  = 3 << 5
+	cborArray = 4 << 5
+	cborMap   = 5 << 5
+	cborTag   = 6 << 5
+
+	// cborBreak ends an indefinite-length item.
+	cborBreak = 0xff
+)
+
+// cborEncoder streams pages as an indefinite-length CBOR array of maps. As
+// with a JSON array, a truncated stream misses the final break.
+type cborEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// Begin implements [pageEncoder].
+func (e *cborEncoder) Begin() error {
+	_, err := e.w.Write([]byte{cborArray | 31})
+	return err
+}
+
+// Encode implements [pageEncoder].
+func (e *cborEncoder) Encode(p *Page) error {
+	if err := checkUTF8(p); err != nil {
+		return err
+	}
+	e.buf = appendCBORPage(e.buf[:0], p)
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// End implements [pageEncoder].
+func (e *cborEncoder) End() error {
+	_, err := e.w.Write([]byte{cborBreak})
+	return err
+}
+
+// appendCBORPage appends p as a map with the keys of the JSON encoding.
+// UpdatedAt is a standard date time string (tag 0), written like the JSON
+// encoding with nanoseconds and the offset of the time.
+func appendCBORPage(b []byte, p *Page) []byte {
+	b = appendCBORHead(b, cborMap, 4)
+	b = appendCBORString(b, "ID")
+	b = appendCBORInt(b, p.ID)
+	b = appendCBORString(b, "UpdatedAt")
+	b = appendCBORTime(b, p.UpdatedAt)
+	b = appendCBORString(b, "Title")
+	b = appendCBORString(b, p.Title)
+	b = appendCBORString(b, "Text")
+	b = appendCBORString(b, p.Text)
+	return b
+}
+
+// appendCBORHead appends the head of an item of the major type t with the
+// argument n.
+func appendCBORHead(b []byte, t byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, t|byte(n))
+	case n <= 0xff:
+		return append(b, t|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, t|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, t|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, t|27), n)
+	}
+}
+
+func appendCBORInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendCBORHead(b, cborNeg, uint64(-1-v))
+	}
+	return appendCBORHead(b, cborUint, uint64(v))
+}
+
+// appendCBORTime appends t as a RFC 3339 string of tag 0.
+func appendCBORTime(b []byte, t time.Time) []byte {
+	var buf [64]byte
+	s := t.AppendFormat(buf[:0], time.RFC3339Nano)
+	b = appendCBORHead(b, cborTag, 0)
+	b = appendCBORHead(b, cborText, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendCBORString(b []byte, s string) []byte {
+	b = appendCBORHead(b, cborText, uint64(len(s)))
+	return append(b, s...)
+}
+
+// checkUTF8 reports an error if the strings of p are not valid UTF-8. Like
+// the JSON encoder, the binary encoders refuse them as the text strings of
+// both formats must be UTF-8.
+func checkUTF8(p *Page) error {
+	if !utf8.ValidString(p.Title) || !utf8.ValidString(p.Text) {
+		return fmt.Errorf("page %d: invalid UTF-8", p.ID)
+	}
+	return nil
+}
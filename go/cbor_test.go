@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func decodeCBOR(body []byte) ([]map[string]any, error) {
+	mode, err := cbor.DecOptions{ExtraReturnErrors: cbor.ExtraDecErrorUnknownField}.DecMode()
+	if err != nil {
+		return nil, err
+	}
+	var rest []byte
+	var list []Page
+	if rest, err = mode.UnmarshalFirst(body, &list); err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected trailing data: %d bytes", len(rest))
+	}
+
+	pages := []map[string]any{}
+	for _, p := range list {
+		pages = append(pages, map[string]any{
+			"ID":        float64(p.ID),
+			"UpdatedAt": p.UpdatedAt.Format(time.RFC3339Nano),
+			"Title":     p.Title,
+			"Text":      p.Text,
+		})
+	}
+	return pages, nil
+}
+
+func TestAppendCBOR(t *testing.T) {
+	for _, v := range []int64{0, 23, 24, 0xff, 0x100, 0xffff, 0x10000, 0xffffffff, 0x100000000, math.MaxInt64, -1, -24, -25, math.MinInt64} {
+		var got int64
+		if err := cbor.Unmarshal(appendCBORInt(nil, v), &got); err != nil || got != v {
+			t.Fatalf("int %d: got=%d err=%v", v, got, err)
+		}
+	}
+
+	for _, n := range []int{0, 23, 24, 0xff, 0x100, 0xffff, 0x10000} {
+		v := string(bytes.Repeat([]byte("a"), n))
+		var got string
+		if err := cbor.Unmarshal(appendCBORString(nil, v), &got); err != nil || got != v {
+			t.Fatalf("string of %d bytes: got %d bytes, err=%v", n, len(got), err)
+		}
+	}
+
+	for _, v := range []time.Time{
+		time.Unix(1700000000, 0).UTC(),
+		time.Unix(1700000000, 123456789).UTC(),
+		time.Date(2001, 1, 15, 8, 30, 0, 500000000, time.FixedZone("", 2*3600)),
+	} {
+		var got time.Time
+		if err := cbor.Unmarshal(appendCBORTime(nil, v), &got); err != nil || !got.Equal(v) {
+			t.Fatalf("time %v: got=%v err=%v", v, got, err)
+		}
+	}
+
+	// A truncated stream misses the final break.
+	var buf bytes.Buffer
+	e := &cborEncoder{w: &buf}
+	_ = e.Begin()
+	_ = e.Encode(&Page{ID: 1, UpdatedAt: time.Unix(1700000000, 0), Title: "a", Text: "b"})
+	if _, err := decodeCBOR(buf.Bytes()); err == nil {
+		t.Fatalf("expects an error for a truncated stream")
+	}
+	if err := e.Encode(&Page{Title: "\xff"}); err == nil {
+		t.Fatalf("expects an error for invalid UTF-8")
+	}
+}
@@ -0,0 +1,353 @@
+// Package client consumes the page streams served by the Stream server.
+//
+//	for p, err := range client.StreamPages(ctx, client.Options{URL: "http://localhost:8080"}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(p.Title)
+//	}
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// ErrTruncated is returned when a stream ends before its last page.
+var ErrTruncated = errors.New("client: truncated stream")
+
+// ErrUnordered is returned for a stream of pages not in ID order, such as an
+// unordered parallel read: it cannot be resumed after its last page.
+var ErrUnordered = errors.New("client: unordered stream")
+
+// StatusError is returned when the server answers with an unexpected status.
+type StatusError struct {
+	Code    int
+	Message string
+	// ErrorCode is the machine-readable code of the error, e.g.
+	// invalid_parameter.
+	ErrorCode string
+}
+
+// Error implements [error].
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("client: unexpected status %d", e.Code)
+	}
+	return fmt.Sprintf("client: unexpected status %d: %v", e.Code, e.Message)
+}
+
+// Page stores information on a Wiki page.
+type Page struct {
+	ID        int64
+	UpdatedAt time.Time
+	Title     string
+	Text      string
+}
+
+// Options stores the parameters of [StreamPages].
+type Options struct {
+	// URL is the base URL of the server, e.g. http://localhost:8080.
+	URL string
+	// Path is the path of the stream endpoint, /pages.stream by default.
+	Path string
+	// Limit is the maximum number of pages to stream, 0 streams everything.
+	Limit int
+	// After resumes the stream after the page with the given ID.
+	After int64
+	// NDJSON requests newline delimited JSON instead of a JSON array.
+	NDJSON bool
+	// Gzip requests a gzip compressed response.
+	Gzip bool
+	// Retries is the number of times an interrupted stream is resumed.
+	Retries int
+	// RetryDelay is the delay between two attempts, 1s by default.
+	RetryDelay time.Duration
+	// HTTPClient is the client sending the requests, http.DefaultClient by
+	// default.
+	HTTPClient *http.Client
+}
+
+// StreamPages streams pages from the server.
+//
+// An interrupted stream is resumed from the last received page up to
+// opts.Retries times. The iterator stops after the first error.
+func StreamPages(ctx context.Context, opts Options) func(func(Page, error) bool) {
+	path := opts.Path
+	if path == "" {
+		path = "/pages.stream"
+	}
+	return streamPages(ctx, opts, path, nil)
+}
+
+// SearchPages streams the pages with a title containing q. It supports the same
+// options as [StreamPages], except Path.
+func SearchPages(ctx context.Context, opts Options, q string) func(func(Page, error) bool) {
+	return streamPages(ctx, opts, "/pages.search", url.Values{"q": {q}})
+}
+
+// GetPage gets a page by ID.
+func GetPage(ctx context.Context, opts Options, id int64) (Page, error) {
+	return getPayload[Page](ctx, opts, "/pages.get?id="+strconv.FormatInt(id, 10))
+}
+
+// TextSizeStats summarizes the size in bytes of the texts of a group of
+// pages.
+type TextSizeStats struct {
+	Total int64 `json:"total"`
+	Min   int64 `json:"min"`
+	P50   int64 `json:"p50"`
+	P90   int64 `json:"p90"`
+	P99   int64 `json:"p99"`
+	Max   int64 `json:"max"`
+}
+
+// MonthStats stores the statistics of the pages updated in a month.
+type MonthStats struct {
+	Month    string        `json:"month"`
+	Pages    int64         `json:"pages"`
+	TextSize TextSizeStats `json:"text_size"`
+}
+
+// PageStats stores the statistics of the pages grouped by month of update.
+type PageStats struct {
+	Pages  int64        `json:"pages"`
+	Months []MonthStats `json:"months"`
+}
+
+// GetPageStats gets the page statistics computed and cached by the server.
+func GetPageStats(ctx context.Context, opts Options) (PageStats, error) {
+	return getPayload[PageStats](ctx, opts, "/pages.stats")
+}
+
+// getPayload gets path and decodes the payload of the response.
+func getPayload[T any](ctx context.Context, opts Options, path string) (T, error) {
+	var zero T
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.URL+path, nil)
+	if err != nil {
+		return zero, fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := httpClient(opts).Do(req)
+	if err != nil {
+		return zero, fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return zero, newStatusError(resp)
+	}
+
+	var body struct {
+		Payload T `json:"payload"`
+	}
+	err = jsonv2.UnmarshalRead(resp.Body, &body)
+	if err != nil {
+		return zero, decodeError(err)
+	}
+	return body.Payload, nil
+}
+
+func streamPages(ctx context.Context, opts Options, path string, query url.Values) func(func(Page, error) bool) {
+	return func(yield func(Page, error) bool) {
+		var zero Page
+
+		s := stream{opts: opts, path: path, query: query, after: opts.After}
+		for attempt := 0; ; attempt++ {
+			err := s.do(ctx, yield)
+			if err == nil || errors.Is(err, errStopped) {
+				return
+			}
+			if attempt >= opts.Retries || !retryable(err) || ctx.Err() != nil {
+				yield(zero, err)
+				return
+			}
+
+			delay := opts.RetryDelay
+			if delay == 0 {
+				delay = time.Second
+			}
+			select {
+			case <-ctx.Done():
+				yield(zero, ctx.Err())
+				return
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// errStopped is returned when the consumer stops the iteration.
+var errStopped = errors.New("client: stopped")
+
+// stream tracks the progress of a stream across attempts.
+type stream struct {
+	opts     Options
+	path     string
+	query    url.Values
+	after    int64
+	received int
+}
+
+// do sends a request and yields the pages of the response.
+func (s *stream) do(ctx context.Context, yield func(Page, error) bool) error {
+	if s.opts.Limit > 0 && s.received >= s.opts.Limit {
+		return nil
+	}
+
+	req, err := s.newRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient(s.opts).Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp)
+	}
+	if resp.Header.Get("X-Page-Order") == "unordered" {
+		return ErrUnordered
+	}
+
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("gzip: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	d := jsontext.NewDecoder(body)
+	if !s.opts.NDJSON {
+		tok, err := d.ReadToken()
+		if err != nil {
+			return decodeError(err)
+		}
+		if tok.Kind() != '[' {
+			return fmt.Errorf("decode: expects array, got %v", tok.Kind())
+		}
+	}
+
+	for {
+		kind := d.PeekKind()
+		if kind == ']' && !s.opts.NDJSON {
+			break
+		}
+		if kind == 0 {
+			_, err := d.ReadToken()
+			if errors.Is(err, io.EOF) && s.opts.NDJSON {
+				return nil
+			}
+			return decodeError(err)
+		}
+
+		var p Page
+		err := jsonv2.UnmarshalDecode(d, &p)
+		if err != nil {
+			return decodeError(err)
+		}
+		s.after = p.ID
+		s.received++
+		if !yield(p, nil) {
+			return errStopped
+		}
+	}
+
+	if _, err := d.ReadToken(); err != nil {
+		return decodeError(err)
+	}
+	return nil
+}
+
+// newRequest creates the request resuming the stream after the last page.
+func (s *stream) newRequest(ctx context.Context) (*http.Request, error) {
+	u, err := url.Parse(s.opts.URL + s.path)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+
+	query := u.Query()
+	for k, v := range s.query {
+		query[k] = v
+	}
+	if s.opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(s.opts.Limit-s.received))
+	}
+	if s.after > 0 {
+		query.Set("after", strconv.FormatInt(s.after, 10))
+	}
+	if s.opts.NDJSON {
+		query.Set("format", "ndjson")
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	if s.opts.Gzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	} else {
+		// Disables the transparent compression of the transport.
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+	return req, nil
+}
+
+func httpClient(opts Options) *http.Client {
+	if opts.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return opts.HTTPClient
+}
+
+// newStatusError reads the error envelope of the server.
+func newStatusError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	_ = jsonv2.UnmarshalRead(io.LimitReader(resp.Body, 1<<16), &body)
+	return &StatusError{Code: resp.StatusCode, Message: body.Error, ErrorCode: body.Code}
+}
+
+// decodeError reports an unexpected end of stream as [ErrTruncated].
+func decodeError(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %w", ErrTruncated, err)
+	}
+	return fmt.Errorf("decode: %w", err)
+}
+
+// retryable reports whether a stream failing with err can be resumed.
+func retryable(err error) bool {
+	if errors.Is(err, ErrUnordered) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code >= 500
+	}
+	var syntax *jsontext.SyntacticError
+	if errors.As(err, &syntax) && !errors.Is(err, ErrTruncated) {
+		return false
+	}
+	var semantic *jsonv2.SemanticError
+	return !errors.As(err, &semantic)
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newServer creates a server streaming count pages. It aborts the first
+// response after failAfter pages when failAfter is positive.
+func newServer(t *testing.T, count, failAfter int) *httptest.Server {
+	t.Helper()
+
+	failed := false
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		after, _ := strconv.Atoi(query.Get("after"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		ndjson := query.Get("format") == "ndjson"
+
+		var out io.Writer = w
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+		}
+
+		if !ndjson {
+			fmt.Fprint(out, "[")
+		}
+		sent := 0
+		for id := after + 1; id <= count; id++ {
+			if limit > 0 && sent >= limit {
+				break
+			}
+			if !failed && failAfter > 0 && sent == failAfter {
+				failed = true
+				if gz, ok := out.(*gzip.Writer); ok {
+					_ = gz.Flush()
+				}
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			if !ndjson && sent > 0 {
+				fmt.Fprint(out, ",")
+			}
+			fmt.Fprintf(out, `{"ID":%d,"UpdatedAt":"2023-10-20T00:00:00Z","Title":"page %d","Text":"a\nb"}`, id, id)
+			if ndjson {
+				fmt.Fprint(out, "\n")
+			}
+			sent++
+		}
+		if !ndjson {
+			fmt.Fprint(out, "]")
+		}
+	}))
+}
+
+func collect(t *testing.T, opts Options) ([]int64, error) {
+	t.Helper()
+
+	var ids []int64
+	for p, err := range StreamPages(context.Background(), opts) {
+		if err != nil {
+			return ids, err
+		}
+		if p.Title != "page "+strconv.FormatInt(p.ID, 10) || p.Text != "a\nb" {
+			t.Fatalf("unexpected page: %+v", p)
+		}
+		ids = append(ids, p.ID)
+	}
+	return ids, nil
+}
+
+func TestStreamPages(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantLen int
+	}{
+		{name: "json", opts: Options{}, wantLen: 10},
+		{name: "ndjson", opts: Options{NDJSON: true}, wantLen: 10},
+		{name: "gzip", opts: Options{Gzip: true}, wantLen: 10},
+		{name: "limit", opts: Options{Limit: 3}, wantLen: 3},
+		{name: "after", opts: Options{After: 8, NDJSON: true}, wantLen: 2},
+	}
+
+	srv := newServer(t, 10, 0)
+	defer srv.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.URL = srv.URL
+			ids, err := collect(t, tt.opts)
+			if err != nil {
+				t.Fatalf("stream pages: %v", err)
+			}
+			if len(ids) != tt.wantLen {
+				t.Fatalf("unexpected page count: expects=%d got=%d", tt.wantLen, len(ids))
+			}
+		})
+	}
+}
+
+func TestStreamPagesTruncated(t *testing.T) {
+	for _, ndjson := range []bool{false, true} {
+		srv := newServer(t, 10, 4)
+		ids, err := collect(t, Options{URL: srv.URL, NDJSON: ndjson})
+		srv.Close()
+		if !errors.Is(err, ErrTruncated) {
+			t.Fatalf("ndjson=%v: expects truncated error, got %v", ndjson, err)
+		}
+		if len(ids) != 4 {
+			t.Fatalf("ndjson=%v: unexpected page count: expects=4 got=%d", ndjson, len(ids))
+		}
+	}
+}
+
+func TestStreamPagesResume(t *testing.T) {
+	srv := newServer(t, 10, 4)
+	defer srv.Close()
+
+	ids, err := collect(t, Options{URL: srv.URL, Limit: 8, Retries: 1, RetryDelay: 1, Gzip: true})
+	if err != nil {
+		t.Fatalf("stream pages: %v", err)
+	}
+	want := []int64{1, 2, 3, 4, 5, 6, 7, 8}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Fatalf("unexpected pages: expects=%v got=%v", want, ids)
+	}
+}
+
+func TestStreamPagesStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"ok":false,"error":"invalid limit","code":"invalid_parameter"}`)
+	}))
+	defer srv.Close()
+
+	_, err := collect(t, Options{URL: srv.URL, Retries: 3})
+	var status *StatusError
+	if !errors.As(err, &status) || status.Code != http.StatusBadRequest || !strings.Contains(status.Message, "invalid limit") || status.ErrorCode != "invalid_parameter" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStreamPagesUnordered(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("X-Page-Order", "unordered")
+		fmt.Fprint(w, `{"ID":2,"UpdatedAt":"2023-10-20T00:00:00Z","Title":"page 2","Text":"a\nb"}`+"\n")
+	}))
+	defer srv.Close()
+
+	_, err := collect(t, Options{URL: srv.URL, NDJSON: true, Retries: 3, RetryDelay: 1})
+	if !errors.Is(err, ErrUnordered) || requests != 1 {
+		t.Fatalf("unexpected error after %d requests: %v", requests, err)
+	}
+}
+
+func TestGetPageStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pages.stats" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"payload":{"pages":3,"months":[{"month":"2024-01","pages":3,"text_size":{"total":30,"min":5,"p50":10,"p90":15,"p99":15,"max":15}}]}}`)
+	}))
+	defer srv.Close()
+
+	stats, err := GetPageStats(context.Background(), Options{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("get page stats: %v", err)
+	}
+	if stats.Pages != 3 || len(stats.Months) != 1 || stats.Months[0].Month != "2024-01" || stats.Months[0].TextSize.Max != 15 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
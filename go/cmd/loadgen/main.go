@@ -0,0 +1,374 @@
+// Loadgen benchmarks the endpoints of a running Stream server and prints the
+// results as a markdown table.
+//
+// The server heap is read from the server logs when -log is set:
+//
+//	go run . > server.log
+//	go run ./cmd/loadgen -log server.log
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// config stores the command-line parameters.
+type config struct {
+	URL          string
+	Log          string
+	Endpoints    []string
+	Compressions []string
+	Concurrency  int
+	Repeat       int
+	Limit        int
+}
+
+// defaultEndpoints lists the encoders of the server.
+const defaultEndpoints = "/pages.list.std,/pages.list.exp,/pages.list.iter,/pages.list.slice," +
+	"/pages.stream.std,/pages.stream.iter,/pages.stream.slice,/pages.stream.marshaler"
+
+func main() {
+	var cfg config
+	var endpoints, compressions string
+	flag.StringVar(&cfg.URL, "url", "http://127.0.0.1:8080", "URL of the Stream server")
+	flag.StringVar(&cfg.Log, "log", "", "path to the server log, used to read the heap size")
+	flag.StringVar(&endpoints, "endpoints", defaultEndpoints, "comma separated list of endpoints")
+	flag.StringVar(&compressions, "compression", "none", "comma separated list of compressions: none or gzip")
+	flag.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent requests")
+	flag.IntVar(&cfg.Repeat, "repeat", 3, "number of requests per endpoint")
+	flag.IntVar(&cfg.Limit, "limit", 0, "limit query parameter, 0 requests everything")
+	flag.Parse()
+
+	if cfg.Concurrency < 1 || cfg.Repeat < 1 {
+		fmt.Fprintf(os.Stderr, "-concurrency and -repeat must be at least 1\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg.Endpoints = strings.Split(endpoints, ",")
+	cfg.Compressions = strings.Split(compressions, ",")
+	for _, c := range cfg.Compressions {
+		if c != "none" && c != "gzip" {
+			fatalf("invalid compression: %q\n", c)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var logs *logReader
+	if cfg.Log != "" {
+		var err error
+		logs, err = newLogReader(cfg.Log)
+		if err != nil {
+			fatalf("fail to open log: %v\n", err)
+		}
+		defer logs.Close()
+	}
+
+	var results []*result
+	for _, endpoint := range cfg.Endpoints {
+		for _, compression := range cfg.Compressions {
+			fmt.Fprintf(os.Stderr, "running %v (%v)...\n", endpoint, compression)
+			r, err := run(ctx, cfg, endpoint, compression)
+			if err != nil {
+				fatalf("fail to run %v: %v\n", endpoint, err)
+			}
+			if logs != nil {
+				r.heaps, err = logs.Heaps(r.tag, cfg.Repeat)
+				if err != nil {
+					fatalf("fail to read log: %v\n", err)
+				}
+			}
+			results = append(results, r)
+		}
+	}
+
+	printTable(os.Stdout, cfg, results)
+}
+
+// result stores the measures of an endpoint.
+type result struct {
+	endpoint    string
+	compression string
+	tag         string
+
+	elapsed   time.Duration
+	size      int64
+	ttfbs     []time.Duration
+	durations []time.Duration
+	heaps     []uint64
+	// failures are the statuses of the failed requests, such as a 507 for a
+	// list over the memory budget of the server.
+	failures []int
+}
+
+var runCount int
+
+// run sends cfg.Repeat requests to the endpoint with cfg.Concurrency workers.
+// The requests answered with an error status are counted as failures, the
+// other errors stop the run.
+func run(ctx context.Context, cfg config, endpoint, compression string) (*result, error) {
+	runCount++
+	r := &result{
+		endpoint:    endpoint,
+		compression: compression,
+		tag:         fmt.Sprintf("%d-%d", os.Getpid(), runCount),
+	}
+
+	query := url.Values{"loadgen": {r.tag}}
+	if cfg.Limit > 0 {
+		query.Set("limit", strconv.Itoa(cfg.Limit))
+	}
+	u := cfg.URL + endpoint + "?" + query.Encode()
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	var mu sync.Mutex
+	var firstErr error
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				ttfb, duration, size, err := measure(ctx, client, u, compression)
+
+				mu.Lock()
+				var statusErr *statusError
+				switch {
+				case errors.As(err, &statusErr):
+					r.failures = append(r.failures, statusErr.code)
+				case err != nil:
+					if firstErr == nil {
+						firstErr = err
+					}
+				default:
+					r.ttfbs = append(r.ttfbs, ttfb)
+					r.durations = append(r.durations, duration)
+					r.size += size
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for range cfg.Repeat {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	wg.Wait()
+	r.elapsed = time.Since(start)
+
+	return r, firstErr
+}
+
+// statusError is returned by measure when the response status is not 200.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.code)
+}
+
+// measure sends a request and returns its time to first byte, its duration and
+// the size of the body on the wire.
+func measure(ctx context.Context, client *http.Client, u, compression string) (time.Duration, time.Duration, int64, error) {
+	var ttfb time.Duration
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { ttfb = time.Since(start) },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, u, nil)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if compression == "gzip" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	size, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, 0, &statusError{code: resp.StatusCode}
+	}
+	return ttfb, time.Since(start), size, nil
+}
+
+// printTable writes the results as a markdown table. The measures only cover
+// the successful requests, an endpoint without any is written as failed.
+func printTable(w io.Writer, cfg config, results []*result) {
+	fmt.Fprintf(w, "concurrency=%d repeat=%d limit=%d\n\n", cfg.Concurrency, cfg.Repeat, cfg.Limit)
+	fmt.Fprintln(w, "| endpoint | compression | size | throughput | ttfb p50 | duration p50 | duration max | heap max | failed |")
+	fmt.Fprintln(w, "|----------|-------------|-----:|-----------:|---------:|-------------:|-------------:|---------:|-------:|")
+	for _, r := range results {
+		if len(r.durations) == 0 {
+			fmt.Fprintf(w, "| %v | %v | n/a | n/a | n/a | n/a | n/a | n/a | %v |\n", r.endpoint, r.compression, formatFailures(r.failures))
+			continue
+		}
+		heap := "n/a"
+		if len(r.heaps) > 0 {
+			heap = formatByteCount(slices.Max(r.heaps))
+		}
+		fmt.Fprintf(w, "| %v | %v | %v | %v/s | %v | %v | %v | %v | %v |\n",
+			r.endpoint,
+			r.compression,
+			formatByteCount(uint64(r.size/int64(max(len(r.durations), 1)))),
+			formatByteCount(uint64(float64(r.size)/r.elapsed.Seconds())),
+			percentile(r.ttfbs, 50).Round(time.Millisecond),
+			percentile(r.durations, 50).Round(time.Millisecond),
+			slices.Max(r.durations).Round(time.Millisecond),
+			heap,
+			formatFailures(r.failures),
+		)
+	}
+}
+
+// formatFailures writes the number of failed requests and their statuses,
+// such as "3 (507)".
+func formatFailures(statuses []int) string {
+	if len(statuses) == 0 {
+		return "0"
+	}
+	codes := slices.Compact(slices.Sorted(slices.Values(statuses)))
+	s := make([]string, len(codes))
+	for i, code := range codes {
+		s[i] = strconv.Itoa(code)
+	}
+	return fmt.Sprintf("%d (%v)", len(statuses), strings.Join(s, ", "))
+}
+
+func percentile(values []time.Duration, p int) time.Duration {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+// logReader reads the request logs of the server.
+type logReader struct {
+	f       *os.File
+	r       *bufio.Reader
+	partial string
+}
+
+func newLogReader(path string) (*logReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &logReader{f: f, r: bufio.NewReader(f)}, nil
+}
+
+var logLineRegexp = regexp.MustCompile(`url=("(?:[^"\\]|\\.)*"|\S+) .*heap=(\S+)`)
+
+// Heaps reads the heap sizes logged for the requests tagged with tag. It
+// waits up to a second for the server to write its logs.
+func (l *logReader) Heaps(tag string, count int) ([]uint64, error) {
+	var heaps []uint64
+	deadline := time.Now().Add(time.Second)
+	for len(heaps) < count && time.Now().Before(deadline) {
+		line, err := l.r.ReadString('\n')
+		if err == io.EOF {
+			// Keeps the partial line for the next read.
+			l.partial += line
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, l.partial = l.partial+line, ""
+
+		m := logLineRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		u := m[1]
+		if unquoted, err := strconv.Unquote(u); err == nil {
+			u = unquoted
+		}
+		if !strings.Contains(u, "loadgen="+tag) {
+			continue
+		}
+		heap, err := parseByteCount(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("parse heap %q: %v", m[2], err)
+		}
+		heaps = append(heaps, heap)
+	}
+	return heaps, nil
+}
+
+func (l *logReader) Close() error {
+	return l.f.Close()
+}
+
+const byteUnits = "kMGTPE"
+
+func formatByteCount(b uint64) string {
+	const unit = 1000
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(b)/float64(div), byteUnits[exp])
+}
+
+// parseByteCount parses the sizes written by formatByteCount.
+func parseByteCount(s string) (uint64, error) {
+	s = strings.TrimSuffix(s, "B")
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+	mult := 1.0
+	if i := strings.IndexByte(byteUnits, s[len(s)-1]); i >= 0 {
+		s = s[:len(s)-1]
+		for range i + 1 {
+			mult *= 1000
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(v * mult), nil
+}
+
+func fatalf(format string, v ...any) {
+	fmt.Fprintf(os.Stderr, format, v...)
+	os.Exit(1)
+}
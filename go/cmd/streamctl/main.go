@@ -0,0 +1,387 @@
+// Streamctl dumps and inspects the pages served by a running Stream server.
+//
+//	streamctl [-url URL] dump [-format ndjson] [-out pages.jsonl] [-limit N] [-resume]
+//	streamctl [-url URL] get <id>
+//	streamctl [-url URL] search [-limit N] <q>
+//	streamctl [-url URL] stats [-scan] [-limit N]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+
+	"github.com/y1w5/stream/go/client"
+)
+
+const usage = `usage: streamctl [-url URL] <command> [flags] [args]
+
+commands:
+  dump     dump pages into a file
+  get      print a page
+  search   print the pages with a title containing a string
+  stats    print statistics on the pages
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	opts := client.Options{Retries: 3}
+	flag.StringVar(&opts.URL, "url", "http://127.0.0.1:8080", "URL of the Stream server")
+	flag.BoolVar(&opts.Gzip, "gzip", false, "request gzip compressed responses")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	commands := map[string]func(context.Context, client.Options, []string) error{
+		"dump":   dump,
+		"get":    get,
+		"search": search,
+		"stats":  stats,
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := cmd(ctx, opts, flag.Args()[1:])
+	if err != nil {
+		fatalf("%v: %v\n", flag.Arg(0), err)
+	}
+}
+
+func dump(ctx context.Context, opts client.Options, args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	format := fs.String("format", "ndjson", "output format: json or ndjson")
+	out := fs.String("out", "pages.jsonl", "output file, - for stdout")
+	resume := fs.Bool("resume", false, "resume an interrupted ndjson dump")
+	fs.IntVar(&opts.Limit, "limit", 0, "maximum number of pages, 0 dumps everything")
+	_ = fs.Parse(args)
+
+	if *format != "json" && *format != "ndjson" {
+		return fmt.Errorf("invalid format: %q", *format)
+	}
+	if *resume && (*format != "ndjson" || *out == "-") {
+		return fmt.Errorf("resume requires the ndjson format and an output file")
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if *resume {
+			after, err := lastPage(*out)
+			if err != nil {
+				return fmt.Errorf("resume: %v", err)
+			}
+			opts.After = after
+			if opts.Limit > 0 {
+				// Only the limit needs the number of pages already dumped.
+				count, err := countLines(*out)
+				if err != nil {
+					return fmt.Errorf("resume: %v", err)
+				}
+				opts.Limit = max(opts.Limit-count, 0)
+				if opts.Limit == 0 {
+					return nil
+				}
+			}
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+
+		f, err := os.OpenFile(*out, flags, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	buf := bufio.NewWriter(w)
+	e := jsontext.NewEncoder(buf)
+	if *format == "json" {
+		if err := e.WriteToken(jsontext.ArrayStart); err != nil {
+			return err
+		}
+	}
+
+	opts.NDJSON = true
+	bar := newProgress("dump")
+	for p, err := range client.StreamPages(ctx, opts) {
+		if err != nil {
+			_ = buf.Flush()
+			bar.Finish()
+			return err
+		}
+		if err := jsonv2.MarshalEncode(e, p); err != nil {
+			return err
+		}
+		bar.Add(p)
+	}
+	bar.Finish()
+
+	if *format == "json" {
+		if err := e.WriteToken(jsontext.ArrayEnd); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+// lastPageChunk is the size of the chunks read from the end of a dump.
+const lastPageChunk = 64 << 10
+
+// lastPage returns the ID of the last complete page of a ndjson dump,
+// truncating a partially written last line. The dump is read backwards from
+// its end until the start of the last complete line.
+func lastPage(path string) (int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// data is the end of the file read so far, it ends with the newline of
+	// the last complete line once end is found.
+	var data []byte
+	var line []byte
+	end := int64(0)
+	for off := stat.Size(); off > 0 && line == nil; {
+		n := min(off, lastPageChunk)
+		off -= n
+		chunk := make([]byte, n, int(n)+len(data))
+		if _, err := f.ReadAt(chunk, off); err != nil {
+			return 0, err
+		}
+		data = append(chunk, data...)
+
+		if end == 0 {
+			i := bytes.LastIndexByte(data, '\n')
+			if i < 0 {
+				continue
+			}
+			end = off + int64(i) + 1
+			data = data[:i+1]
+		}
+		start := bytes.LastIndexByte(data[:len(data)-1], '\n')
+		if start >= 0 || off == 0 {
+			line = data[start+1:]
+		}
+	}
+
+	if end < stat.Size() {
+		if err := f.Truncate(end); err != nil {
+			return 0, err
+		}
+	}
+	if line == nil {
+		return 0, nil
+	}
+	var p client.Page
+	if err := jsonv2.Unmarshal(line, &p); err != nil {
+		return 0, fmt.Errorf("decode last page: %v", err)
+	}
+	return p.ID, nil
+}
+
+// countLines returns the number of lines of path, it reads the file by
+// chunks.
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var count int
+	buf := make([]byte, lastPageChunk)
+	for {
+		n, err := f.Read(buf)
+		count += bytes.Count(buf[:n], []byte{'\n'})
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func get(ctx context.Context, opts client.Options, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	_ = fs.Parse(args)
+
+	var id int64
+	if _, err := fmt.Sscan(fs.Arg(0), &id); err != nil || fs.NArg() != 1 {
+		return fmt.Errorf("usage: get <id>")
+	}
+
+	p, err := client.GetPage(ctx, opts, id)
+	if err != nil {
+		return err
+	}
+	return printPage(p)
+}
+
+func search(ctx context.Context, opts client.Options, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	fs.IntVar(&opts.Limit, "limit", 20, "maximum number of pages, 0 prints everything")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: search [-limit N] <q>")
+	}
+
+	for p, err := range client.SearchPages(ctx, opts, fs.Arg(0)) {
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d\t%v\t%v\n", p.ID, p.UpdatedAt.Format(time.DateOnly), p.Title)
+	}
+	return nil
+}
+
+// stats prints the statistics of /pages.stats, computed and cached by the
+// server. With -scan, they are computed from the streamed pages instead.
+func stats(ctx context.Context, opts client.Options, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	scan := fs.Bool("scan", false, "stream the pages and compute the statistics on the client")
+	fs.IntVar(&opts.Limit, "limit", 0, "maximum number of pages read by -scan, 0 reads everything")
+	_ = fs.Parse(args)
+
+	if !*scan {
+		if opts.Limit > 0 {
+			return fmt.Errorf("limit requires -scan")
+		}
+		return serverStats(ctx, opts)
+	}
+
+	var count, size, maxSize int
+	var first, last time.Time
+	opts.NDJSON = true
+	bar := newProgress("stats")
+	for p, err := range client.StreamPages(ctx, opts) {
+		if err != nil {
+			bar.Finish()
+			return err
+		}
+		bar.Add(p)
+
+		count++
+		size += len(p.Text)
+		maxSize = max(maxSize, len(p.Text))
+		if first.IsZero() || p.UpdatedAt.Before(first) {
+			first = p.UpdatedAt
+		}
+		if p.UpdatedAt.After(last) {
+			last = p.UpdatedAt
+		}
+	}
+	bar.Finish()
+
+	fmt.Printf("pages:         %d\n", count)
+	fmt.Printf("text size:     %d\n", size)
+	if count > 0 {
+		fmt.Printf("avg text size: %d\n", size/count)
+	}
+	fmt.Printf("max text size: %d\n", maxSize)
+	fmt.Printf("first update:  %v\n", first.Format(time.DateTime))
+	fmt.Printf("last update:   %v\n", last.Format(time.DateTime))
+	return nil
+}
+
+func serverStats(ctx context.Context, opts client.Options) error {
+	stats, err := client.GetPageStats(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	var size, maxSize int64
+	for _, m := range stats.Months {
+		size += m.TextSize.Total
+		maxSize = max(maxSize, m.TextSize.Max)
+	}
+	fmt.Printf("pages:         %d\n", stats.Pages)
+	fmt.Printf("text size:     %d\n", size)
+	if stats.Pages > 0 {
+		fmt.Printf("avg text size: %d\n", size/stats.Pages)
+	}
+	fmt.Printf("max text size: %d\n", maxSize)
+	if n := len(stats.Months); n > 0 {
+		fmt.Printf("first month:   %v\n", stats.Months[0].Month)
+		fmt.Printf("last month:    %v\n", stats.Months[n-1].Month)
+	}
+	return nil
+}
+
+func printPage(p client.Page) error {
+	e := jsontext.NewEncoder(os.Stdout, jsontext.Multiline(true))
+	return jsonv2.MarshalEncode(e, p)
+}
+
+// progress prints the progress of a stream on stderr.
+type progress struct {
+	name  string
+	start time.Time
+	last  time.Time
+	pages int
+	bytes int
+}
+
+func newProgress(name string) *progress {
+	now := time.Now()
+	return &progress{name: name, start: now, last: now}
+}
+
+// Add records a page and refreshes the output twice per second.
+func (p *progress) Add(page client.Page) {
+	p.pages++
+	p.bytes += len(page.Title) + len(page.Text)
+	if time.Since(p.last) < 500*time.Millisecond {
+		return
+	}
+	p.last = time.Now()
+	p.print()
+}
+
+// Finish prints the final progress.
+func (p *progress) Finish() {
+	p.print()
+	fmt.Fprintln(os.Stderr)
+}
+
+func (p *progress) print() {
+	elapsed := time.Since(p.start)
+	rate := float64(p.pages) / max(elapsed.Seconds(), 1e-3)
+	fmt.Fprintf(os.Stderr, "\r%v: %d pages, %.1f MB, %.0f p/s, %v",
+		p.name, p.pages, float64(p.bytes)/1e6, rate, elapsed.Round(time.Second))
+}
+
+func fatalf(format string, v ...any) {
+	fmt.Fprintf(os.Stderr, format, v...)
+	os.Exit(1)
+}
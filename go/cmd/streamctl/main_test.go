@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLastPage(t *testing.T) {
+	long := `{"ID":3,"Title":"Long","Text":"` + strings.Repeat("x", 2*lastPageChunk) + `"}` + "\n"
+	tests := []struct {
+		name   string
+		data   string
+		want   int64
+		remain string
+	}{
+		{name: "empty", data: "", want: 0, remain: ""},
+		{name: "single line", data: `{"ID":1}` + "\n", want: 1, remain: `{"ID":1}` + "\n"},
+		{name: "partial line", data: `{"ID":1}` + "\n" + `{"ID":2}` + "\n" + `{"ID":3,"Ti`, want: 2, remain: `{"ID":1}` + "\n" + `{"ID":2}` + "\n"},
+		{name: "only partial line", data: `{"ID":1,"Ti`, want: 0, remain: ""},
+		{name: "long line", data: `{"ID":2}` + "\n" + long, want: 3, remain: `{"ID":2}` + "\n" + long},
+		{name: "long partial line", data: long + `{"ID":4,"Text":"` + strings.Repeat("y", 2*lastPageChunk), want: 3, remain: long},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pages.jsonl")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			got, err := lastPage(path)
+			if err != nil {
+				t.Fatalf("last page: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("unexpected ID: expects=%d got=%d", tt.want, got)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(data) != tt.remain {
+				t.Fatalf("unexpected file: expects %d bytes, got %d", len(tt.remain), len(data))
+			}
+		})
+	}
+
+	// A missing dump starts from the beginning.
+	if got, err := lastPage(filepath.Join(t.TempDir(), "missing.jsonl")); err != nil || got != 0 {
+		t.Fatalf("missing file: unexpected result: %d %v", got, err)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCollection is the name of the collection served at the root routes.
+const defaultCollection = ""
+
+// collection serves a database file. The database can be swapped while
+// requests are running, the old one is closed once they finish.
+type collection struct {
+	name string
+	path string
+	// queryLength is set on the databases, see [DB.queryLength].
+	queryLength int
+
+	mu   sync.Mutex
+	cur  *dbRef
+	file os.FileInfo
+}
+
+// dbRef counts the requests using a database.
+type dbRef struct {
+	db      *DB
+	refs    int
+	retired bool
+}
+
+// openCollection opens the database of a collection.
+func openCollection(name, path string, queryLength int) (*collection, error) {
+	c := &collection{name: name, path: path, queryLength: queryLength}
+	db, file, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	c.cur, c.file = &dbRef{db: db}, file
+	return c, nil
+}
+
+// open opens and checks the database file.
+func (c *collection) open() (*DB, os.FileInfo, error) {
+	file, err := os.Stat(c.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("stat: %v", err)
+	}
+	db, err := NewDB(c.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	db.queryLength = c.queryLength
+	if err := db.Check(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, nil, fmt.Errorf("check %v: %v", c.path, err)
+	}
+	return db, file, nil
+}
+
+// acquire returns the current database, [collection.release] must be called
+// once the request is done with it.
+func (c *collection) acquire() *dbRef {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cur.refs++
+	return c.cur
+}
+
+// release releases a database returned by [collection.acquire].
+func (c *collection) release(ref *dbRef) {
+	c.mu.Lock()
+	ref.refs--
+	done := ref.retired && ref.refs == 0
+	c.mu.Unlock()
+
+	if done {
+		_ = ref.db.Close()
+	}
+}
+
+// db returns the current database without holding it.
+func (c *collection) db() *DB {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cur.db
+}
+
+// reload opens the database file again and switches the new requests to it.
+// The old database is closed once its requests finish.
+func (c *collection) reload() error {
+	db, file, err := c.open()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	old := c.cur
+	c.cur, c.file = &dbRef{db: db}, file
+	old.retired = true
+	done := old.refs == 0
+	c.mu.Unlock()
+
+	if done {
+		return old.db.Close()
+	}
+	return nil
+}
+
+// changed reports whether the database file was replaced since it was opened.
+func (c *collection) changed() bool {
+	file, err := os.Stat(c.path)
+	if err != nil {
+		// The file is being rebuilt.
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !os.SameFile(file, c.file) || file.ModTime() != c.file.ModTime()
+}
+
+// close closes the current database. Retired databases are closed by their
+// last request.
+func (c *collection) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cur.retired = true
+	if c.cur.refs > 0 {
+		return nil
+	}
+	return c.cur.db.Close()
+}
+
+// openCollections opens the database of each collection. The default
+// collection is served from path when it is not empty. queryLength bounds the
+// read-only queries, see [DB.queryLength].
+func openCollections(path string, collections map[string]string, queryLength int) (map[string]*collection, error) {
+	paths := make(map[string]string, len(collections)+1)
+	if path != "" {
+		paths[defaultCollection] = path
+	}
+	for name, path := range collections {
+		if name == "" || strings.ContainsAny(name, "/.") {
+			return nil, fmt.Errorf("invalid collection name %q", name)
+		}
+		paths[name] = path
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no database")
+	}
+
+	cs := make(map[string]*collection, len(paths))
+	for name, path := range paths {
+		c, err := openCollection(name, path, queryLength)
+		if err != nil {
+			_ = closeCollections(cs)
+			return nil, err
+		}
+		cs[name] = c
+	}
+	return cs, nil
+}
+
+// closeCollections closes the database of each collection.
+func closeCollections(cs map[string]*collection) error {
+	var errs []error
+	for name, c := range cs {
+		if err := c.close(); err != nil {
+			errs = append(errs, fmt.Errorf("%q: %v", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sortedCollections returns the collections ordered by name.
+func sortedCollections(cs map[string]*collection) []*collection {
+	sorted := make([]*collection, 0, len(cs))
+	for _, c := range cs {
+		sorted = append(sorted, c)
+	}
+	slices.SortFunc(sorted, func(a, b *collection) int { return cmp.Compare(a.name, b.name) })
+	return sorted
+}
+
+type collectionKey struct{}
+
+// collectionNotFound is returned for unknown collections.
+func collectionNotFound(name string) *apiError {
+	return &apiError{
+		status:  http.StatusNotFound,
+		code:    codeNotFound,
+		message: fmt.Sprintf("collection %q not found", name),
+	}
+}
+
+// withCollection resolves the database of the {collection} path segment. The
+// root routes resolve the default collection. The database is held until the
+// request finishes, a reload does not close it under a running stream.
+func (s *Stream) withCollection(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("collection")
+		c, ok := s.collections[name]
+		if !ok {
+			writeError(w, collectionNotFound(name))
+			return
+		}
+
+		ref := c.acquire()
+		defer c.release(ref)
+		next(w, r.WithContext(context.WithValue(r.Context(), collectionKey{}, ref.db)))
+	}
+}
+
+// dbFor returns the database of the request collection, falling back on the
+// default collection. It fails when the server has no default collection.
+func (s *Stream) dbFor(r *http.Request) (*DB, error) {
+	if db, ok := r.Context().Value(collectionKey{}).(*DB); ok {
+		return db, nil
+	}
+	c, ok := s.collections[defaultCollection]
+	if !ok {
+		return nil, collectionNotFound(defaultCollection)
+	}
+	return c.db(), nil
+}
+
+// Reload reopens the database of the named collections, or of all the
+// collections when no name is given. New requests use the new database, the
+// old one is closed once its in-flight streams finish.
+func (s *Stream) Reload(names ...string) error {
+	cs := sortedCollections(s.collections)
+	if len(names) > 0 {
+		cs = cs[:0]
+		for _, name := range names {
+			c, ok := s.collections[name]
+			if !ok {
+				return collectionNotFound(name)
+			}
+			cs = append(cs, c)
+		}
+	}
+
+	var errs []error
+	for _, c := range cs {
+		errs = append(errs, s.reload(c))
+	}
+	return errors.Join(errs...)
+}
+
+// reload reloads the database of c.
+func (s *Stream) reload(c *collection) error {
+	if err := c.reload(); err != nil {
+		return fmt.Errorf("reload %q: %v", c.name, err)
+	}
+	s.logger.Info("reload database", "collection", c.name, "path", c.path)
+	return nil
+}
+
+// watch reloads the collections whose database file was replaced. It checks
+// the files every interval until ctx is done.
+func (s *Stream) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, c := range sortedCollections(s.collections) {
+			if !c.changed() {
+				continue
+			}
+			if err := s.reload(c); err != nil {
+				s.logger.Error("fail to reload database", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/y1w5/stream/db/synth"
+)
+
+func TestCollections(t *testing.T) {
+	dir := t.TempDir()
+	paths := map[string]string{}
+	for name, count := range map[string]int{"default": 3, "fr": 5} {
+		opts := synth.DefaultOptions()
+		opts.Pages = count
+		opts.MaxTextSize = 500
+		paths[name] = filepath.Join(dir, name+".db")
+		if err := synth.WriteDB(paths[name], opts); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+
+	s, err := NewStream(NewStreamParams{
+		DB:          paths["default"],
+		Collections: map[string]string{"fr": paths["fr"]},
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	t.Cleanup(func() { _ = closeCollections(s.collections) })
+	h := s.handler()
+
+	tests := []struct {
+		path     string
+		status   int
+		wantLen  int
+		wantPage bool
+	}{
+		{path: "/pages.stream", status: http.StatusOK, wantLen: 3},
+		{path: "/fr/pages.stream", status: http.StatusOK, wantLen: 5},
+		{path: "/fr/pages.stream.std", status: http.StatusOK, wantLen: 5},
+		{path: "/fr/pages.list?limit=2", status: http.StatusOK, wantLen: 2},
+		{path: "/fr/pages.get?id=5", status: http.StatusOK, wantPage: true},
+		{path: "/pages.get?id=5", status: http.StatusNotFound},
+		{path: "/de/pages.stream", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest("GET", tt.path, nil))
+		if resp.Code != tt.status {
+			t.Fatalf("%v: unexpected status: expects=%d got=%d", tt.path, tt.status, resp.Code)
+		}
+		if tt.status != http.StatusOK || tt.wantPage {
+			continue
+		}
+		pages, err := decodePages(resp.Body.Bytes())
+		if err != nil {
+			t.Fatalf("%v: decode: %v", tt.path, err)
+		}
+		if len(pages) != tt.wantLen {
+			t.Fatalf("%v: unexpected page count: expects=%d got=%d", tt.path, tt.wantLen, len(pages))
+		}
+	}
+}
+
+func TestCollectionsWithoutDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fr.db")
+	opts := synth.DefaultOptions()
+	opts.Pages = 3
+	if err := synth.WriteDB(path, opts); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+	s, err := NewStream(NewStreamParams{
+		Collections: map[string]string{"fr": path},
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	t.Cleanup(func() { _ = closeCollections(s.collections) })
+
+	// The root routes, and the handlers called without collection, have no
+	// database.
+	resp := httptest.NewRecorder()
+	s.handler().ServeHTTP(resp, httptest.NewRequest("GET", "/pages.stream", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", resp.Code)
+	}
+	resp = httptest.NewRecorder()
+	s.streamPages(resp, httptest.NewRequest("GET", "/pages.stream", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", resp.Code)
+	}
+}
+
+func TestCollectionReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stream.db")
+	writeDB := func(pages int) {
+		t.Helper()
+		opts := synth.DefaultOptions()
+		opts.Pages = pages
+		opts.MaxTextSize = 500
+		if err := synth.WriteDB(path, opts); err != nil {
+			t.Fatalf("write db: %v", err)
+		}
+	}
+	countPages := func(db *DB) int64 {
+		t.Helper()
+		stats, err := db.Stats(context.Background())
+		if err != nil {
+			t.Fatalf("stats: %v", err)
+		}
+		return stats.Pages
+	}
+
+	writeDB(3)
+	s, err := NewStream(NewStreamParams{DB: path, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	t.Cleanup(func() { _ = closeCollections(s.collections) })
+	c := s.collections[defaultCollection]
+
+	// An in-flight stream holds the old database.
+	old := c.acquire()
+	if c.changed() {
+		t.Fatalf("unexpected change before rebuild")
+	}
+	writeDB(5)
+	if !c.changed() {
+		t.Fatalf("expects change after rebuild")
+	}
+
+	if err := s.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := countPages(c.db()); got != 5 {
+		t.Fatalf("unexpected new page count: expects=5 got=%d", got)
+	}
+	if got := countPages(old.db); got != 3 {
+		t.Fatalf("unexpected old page count: expects=3 got=%d", got)
+	}
+
+	c.release(old)
+	if err := old.db.Check(context.Background()); err == nil {
+		t.Fatalf("expects old database closed after release")
+	}
+	if err := s.Reload("missing"); err == nil {
+		t.Fatalf("expects unknown collection error")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// csvColumns are the columns of the CSV and TSV formats, named like the
+// Arrow and Parquet columns.
+var csvColumns = []string{"id", "updated_at", "title", "text"}
+
+// newlineMode is the handling of the line breaks and tabs of the CSV fields.
+type newlineMode string
+
+const (
+	// newlinesQuote quotes the fields holding line breaks, as in RFC 4180.
+	newlinesQuote newlineMode = "quote"
+	// newlinesEscape writes line breaks, tabs and backslashes as \n, \r, \t
+	// and \\, each row holds on a single line.
+	newlinesEscape newlineMode = "escape"
+	// newlinesSpace replaces line breaks and tabs with spaces.
+	newlinesSpace newlineMode = "space"
+)
+
+// csvOptions are the options of the CSV and TSV formats.
+type csvOptions struct {
+	header    bool
+	columns   []string
+	delimiter rune
+	newlines  newlineMode
+}
+
+// parseCSVOptions reads the header, columns, delimiter and newlines query
+// parameters. TSV defaults to tabs and escaped line breaks so that each row
+// holds on a single line.
+func parseCSVOptions(q url.Values, f format) (csvOptions, error) {
+	opts := csvOptions{header: true, columns: csvColumns, delimiter: ',', newlines: newlinesQuote}
+	if f == formatTSV {
+		opts.delimiter, opts.newlines = '\t', newlinesEscape
+	}
+
+	if tmp := q.Get("header"); tmp != "" {
+		header, err := strconv.ParseBool(tmp)
+		if err != nil {
+			return csvOptions{}, invalidParameter("header", tmp)
+		}
+		opts.header = header
+	}
+
+	if tmp := q.Get("columns"); tmp != "" {
+		opts.columns = strings.Split(tmp, ",")
+		for i, c := range opts.columns {
+			if !slices.Contains(csvColumns, c) || slices.Contains(opts.columns[:i], c) {
+				return csvOptions{}, invalidParameter("columns", tmp)
+			}
+		}
+	}
+
+	switch tmp := q.Get("delimiter"); tmp {
+	case "":
+	case "tab":
+		opts.delimiter = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(tmp)
+		if size != len(tmp) || !validDelimiter(r) {
+			return csvOptions{}, invalidParameter("delimiter", tmp)
+		}
+		opts.delimiter = r
+	}
+
+	switch tmp := q.Get("newlines"); tmp {
+	case "":
+	case string(newlinesQuote), string(newlinesEscape), string(newlinesSpace):
+		opts.newlines = newlineMode(tmp)
+	default:
+		return csvOptions{}, invalidParameter("newlines", tmp)
+	}
+	return opts, nil
+}
+
+// validDelimiter reports whether r is accepted as a delimiter by
+// [csv.Writer].
+func validDelimiter(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && r != utf8.RuneError
+}
+
+// csvEncoder streams pages as CSV rows.
+type csvEncoder struct {
+	w      *csv.Writer
+	opts   csvOptions
+	record []string
+}
+
+func newCSVEncoder(w io.Writer, opts csvOptions) *csvEncoder {
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.delimiter
+	return &csvEncoder{w: cw, opts: opts, record: make([]string, len(opts.columns))}
+}
+
+// Begin implements [pageEncoder].
+func (e *csvEncoder) Begin() error {
+	if !e.opts.header {
+		return nil
+	}
+	return e.write(e.opts.columns)
+}
+
+// Encode implements [pageEncoder].
+func (e *csvEncoder) Encode(p *Page) error {
+	for i, c := range e.opts.columns {
+		switch c {
+		case "id":
+			e.record[i] = strconv.FormatInt(p.ID, 10)
+		case "updated_at":
+			e.record[i] = p.UpdatedAt.Format(time.RFC3339Nano)
+		case "title":
+			e.record[i] = e.field(p.Title)
+		case "text":
+			e.record[i] = e.field(p.Text)
+		}
+	}
+	return e.write(e.record)
+}
+
+// End implements [pageEncoder].
+func (e *csvEncoder) End() error { return nil }
+
+// write writes a record and flushes it to the underlying writer, which
+// applies the flush policy.
+func (e *csvEncoder) write(record []string) error {
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+var (
+	newlineEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	newlineReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+)
+
+// field applies the newline mode to a text field.
+func (e *csvEncoder) field(s string) string {
+	switch e.opts.newlines {
+	case newlinesEscape:
+		return newlineEscaper.Replace(s)
+	case newlinesSpace:
+		return newlineReplacer.Replace(s)
+	default:
+		return s
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeCSV(body []byte) ([]map[string]any, error) {
+	return decodeDelimited(body, ',', false)
+}
+
+func decodeTSV(body []byte) ([]map[string]any, error) {
+	return decodeDelimited(body, '\t', true)
+}
+
+// decodeDelimited decodes rows with a header holding all the columns.
+func decodeDelimited(body []byte, comma rune, escaped bool) ([]map[string]any, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.Comma = comma
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || !reflect.DeepEqual(rows[0], csvColumns) {
+		return nil, fmt.Errorf("unexpected header: %q", rows)
+	}
+
+	pages := []map[string]any{}
+	for _, row := range rows[1:] {
+		id, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		title, text := row[2], row[3]
+		if escaped {
+			title, text = unescapeNewlines(title), unescapeNewlines(text)
+		}
+		pages = append(pages, map[string]any{
+			"ID":        float64(id),
+			"UpdatedAt": row[1],
+			"Title":     title,
+			"Text":      text,
+		})
+	}
+	return pages, nil
+}
+
+// unescapeNewlines reverses [newlinesEscape].
+func unescapeNewlines(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func TestCSVEncoder(t *testing.T) {
+	p := &Page{ID: 7, UpdatedAt: time.Date(2024, 1, 2, 3, 4, 5, 120000000, time.FixedZone("", 2*3600)), Title: "A, B", Text: "line \"1\"\n\tline\\2\r\n"}
+
+	tests := []struct {
+		query string
+		f     format
+		want  string
+	}{
+		{
+			f:    formatCSV,
+			want: "id,updated_at,title,text\n7,2024-01-02T03:04:05.12+02:00,\"A, B\",\"line \"\"1\"\"\n\tline\\2\r\n\"\n",
+		},
+		{
+			f:    formatTSV,
+			want: "id\tupdated_at\ttitle\ttext\n7\t2024-01-02T03:04:05.12+02:00\tA, B\t\"line \"\"1\"\"\\n\\tline\\\\2\\r\\n\"\n",
+		},
+		{
+			query: "header=false&columns=text,id&newlines=space",
+			f:     formatCSV,
+			want:  "\"line \"\"1\"\"  line\\2 \",7\n",
+		},
+		{
+			query: "header=0&columns=title&delimiter=;",
+			f:     formatCSV,
+			want:  "\"A, B\"\n",
+		},
+		{
+			query: "header=false&columns=id,title&delimiter=tab&newlines=escape",
+			f:     formatCSV,
+			want:  "7\tA, B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		opts, err := parseCSVOptions(httptest.NewRequest("GET", "/?"+tt.query, nil).URL.Query(), tt.f)
+		if err != nil {
+			t.Fatalf("%v %q: parse options: %v", tt.f, tt.query, err)
+		}
+		var buf bytes.Buffer
+		e := newCSVEncoder(&buf, opts)
+		if err := e.Begin(); err != nil {
+			t.Fatalf("%v %q: begin: %v", tt.f, tt.query, err)
+		}
+		if err := e.Encode(p); err != nil {
+			t.Fatalf("%v %q: encode: %v", tt.f, tt.query, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Fatalf("%v %q: unexpected output:\nexpects=%q\ngot=%q", tt.f, tt.query, tt.want, got)
+		}
+	}
+
+	for _, query := range []string{"header=maybe", "columns=id,foo", "columns=id,id", "columns=,", "delimiter=%22", "delimiter=ab", "newlines=keep"} {
+		if _, err := parseCSVOptions(httptest.NewRequest("GET", "/?"+query, nil).URL.Query(), formatCSV); err == nil {
+			t.Fatalf("%q: expects an error", query)
+		}
+	}
+}
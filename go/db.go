@@ -3,18 +3,43 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"time"
+	"unsafe"
 )
 
-// DBSliceSize is the size of a slice of data.
-//
-// It was selected by trial and error to find the best performing buffer size.
+// DBSliceBytes is the budget of a slice of pages: a slice is cut once the
+// summed size of its titles and texts reaches it. Unlike a number of pages, it
+// bounds the memory of a slice whatever the size of the texts.
+const DBSliceBytes = 8 << 20
+
+// DBSliceSize is the maximum number of pages of a slice, it bounds the slices
+// of short pages.
 const DBSliceSize = 1 << 16
 
 // DB is the database access layer of our application.
 type DB struct {
-	db *sql.DB
+	db   *sql.DB
+	path string
+
+	// query holds the read-only connections of [DB.Query], opened by the
+	// first query: /query is disabled by default.
+	queryOnce sync.Once
+	query     *sql.DB
+	queryErr  error
+	// queryLength is the maximum length of the strings and blobs of the
+	// read-only queries, see [queryLength]. 0 uses [defaultQueryLength].
+	queryLength int
+
+	// sliceBytes is the budget of the slices of pages, [DBSliceBytes] by
+	// default.
+	sliceBytes int
+
+	cache statsCache
 }
 
 // NewDB instanciates a [DB].
@@ -23,21 +48,76 @@ func NewDB(path string) (*DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open %v: %v", path, err)
 	}
+	// Keep the connections of the parallel reads and their page cache.
+	db.SetMaxIdleConns(maxShards)
 
 	return &DB{
-		db: db,
+		db:         db,
+		path:       path,
+		sliceBytes: DBSliceBytes,
 	}, nil
 }
 
 // Close closes allocated ressources.
 func (db *DB) Close() error {
+	// The read-only connections can no longer be opened.
+	db.queryOnce.Do(func() { db.queryErr = errors.New("db: closed") })
+	if db.query != nil {
+		if err := db.query.Close(); err != nil {
+			return err
+		}
+	}
 	if err := db.db.Close(); err != nil {
 		return err
 	}
 	return nil
 }
 
-var listPagesQuery = `SELECT id, updated_at, title, text FROM pages LIMIT ?`
+// Check checks that the database can be queried.
+func (db *DB) Check(ctx context.Context) error {
+	var id int64
+	err := db.db.QueryRowContext(ctx, `SELECT id FROM pages LIMIT 1`).Scan(&id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+// DBStats stores information on the database file.
+type DBStats struct {
+	Pages       int64  `json:"pages"`
+	FileSize    int64  `json:"file_size"`
+	PageSize    int64  `json:"page_size"`
+	JournalMode string `json:"journal_mode"`
+	WAL         bool   `json:"wal"`
+}
+
+// Stats returns information on the database file.
+func (db *DB) Stats(ctx context.Context) (DBStats, error) {
+	var stats DBStats
+	err := db.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pages`).Scan(&stats.Pages)
+	if err != nil {
+		return stats, fmt.Errorf("count: %v", err)
+	}
+	err = db.db.QueryRowContext(ctx, `PRAGMA page_size`).Scan(&stats.PageSize)
+	if err != nil {
+		return stats, fmt.Errorf("page_size: %v", err)
+	}
+	err = db.db.QueryRowContext(ctx, `PRAGMA journal_mode`).Scan(&stats.JournalMode)
+	if err != nil {
+		return stats, fmt.Errorf("journal_mode: %v", err)
+	}
+	stats.WAL = strings.EqualFold(stats.JournalMode, "wal")
+
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return stats, fmt.Errorf("stat: %v", err)
+	}
+	stats.FileSize = info.Size()
+	return stats, nil
+}
+
+var listPagesQuery = `SELECT id, updated_at, title, text FROM pages WHERE id > ? ORDER BY id LIMIT ?`
 
 // Page stores information on a Wiki page.
 type Page struct {
@@ -47,25 +127,37 @@ type Page struct {
 	Text      string
 }
 
-// ListPages lists all pages.
+// ListPages lists all pages. The pages are held in the memory of the request
+// of ctx, it fails once they exceed its budget.
 func (db *DB) ListPages(ctx context.Context, limit int) ([]Page, error) {
-	rows, err := db.db.QueryContext(ctx, listPagesQuery, softLimit(limit))
+	rows, err := db.db.QueryContext(ctx, listPagesQuery, 0, softLimit(limit))
 	if err != nil {
 		return nil, fmt.Errorf("query: %v", err)
 	}
 	defer rows.Close()
 
+	info := streamFromContext(ctx)
 	var pages []Page
+	var held int64
 	for rows.Next() {
 		var p Page
 		err := rows.Scan(&p.ID, &p.UpdatedAt, &p.Title, &p.Text)
 		if err != nil {
+			info.shrink(held)
 			return nil, fmt.Errorf("scan: %v", err)
 		}
 		pages = append(pages, p)
+
+		n := pageMemory(&p)
+		held += n
+		if err := info.grow(n); err != nil {
+			info.shrink(held)
+			return nil, err
+		}
 	}
 
 	if err := rows.Err(); err != nil {
+		info.shrink(held)
 		return nil, fmt.Errorf("next: %v", err)
 	}
 
@@ -74,9 +166,49 @@ func (db *DB) ListPages(ctx context.Context, limit int) ([]Page, error) {
 
 // StreamPages streams pages from the database.
 func (db *DB) StreamPages(ctx context.Context, limit int) func(func(Page, error) bool) {
+	return db.StreamPagesAfter(ctx, 0, limit)
+}
+
+// StreamPagesAfter streams pages with an ID greater than after, in ID order. It
+// allows clients to resume an interrupted stream.
+func (db *DB) StreamPagesAfter(ctx context.Context, after int64, limit int) func(func(Page, error) bool) {
+	return db.streamPages(ctx, listPagesQuery, after, softLimit(limit))
+}
+
+// ErrPageNotFound is returned when a page does not exist.
+var ErrPageNotFound = errors.New("db: page not found")
+
+var getPageQuery = `SELECT id, updated_at, title, text FROM pages WHERE id = ?`
+
+// GetPage gets a page by ID.
+func (db *DB) GetPage(ctx context.Context, id int64) (Page, error) {
+	var p Page
+	err := db.db.QueryRowContext(ctx, getPageQuery, id).Scan(&p.ID, &p.UpdatedAt, &p.Title, &p.Text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Page{}, ErrPageNotFound
+	}
+	if err != nil {
+		return Page{}, fmt.Errorf("scan: %v", err)
+	}
+	return p, nil
+}
+
+var searchPagesQuery = `SELECT id, updated_at, title, text FROM pages
+WHERE id > ? AND title LIKE ? ESCAPE '\' ORDER BY id LIMIT ?`
+
+// SearchPages streams pages with a title containing q, in ID order.
+func (db *DB) SearchPages(ctx context.Context, q string, after int64, limit int) func(func(Page, error) bool) {
+	pattern := "%" + likeEscaper.Replace(q) + "%"
+	return db.streamPages(ctx, searchPagesQuery, after, pattern, softLimit(limit))
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// streamPages streams the pages returned by query.
+func (db *DB) streamPages(ctx context.Context, query string, args ...any) func(func(Page, error) bool) {
 	return func(yield func(Page, error) bool) {
 		var zero Page
-		rows, err := db.db.QueryContext(ctx, listPagesQuery, softLimit(limit))
+		rows, err := db.db.QueryContext(ctx, query, args...)
 		if err != nil {
 			yield(zero, fmt.Errorf("query: %v", err))
 			return
@@ -104,32 +236,41 @@ func (db *DB) StreamPages(ctx context.Context, limit int) func(func(Page, error)
 
 // StreamPageSlice streams pages from the database into slices.
 func (db *DB) StreamPageSlice(ctx context.Context, limit int) func(func([]Page, error) bool) {
+	return db.StreamPageSliceAfter(ctx, 0, limit)
+}
+
+// StreamPageSliceAfter streams pages with an ID greater than after as slices
+// of [DBSliceBytes] of text or [DBSliceSize] pages. The slice and the strings
+// of its pages are reused between iterations: a caller keeping a page after
+// yield returns must clone its title and text.
+func (db *DB) StreamPageSliceAfter(ctx context.Context, after int64, limit int) func(func([]Page, error) bool) {
 	return func(yield func([]Page, error) bool) {
-		rows, err := db.db.QueryContext(ctx, listPagesQuery, softLimit(limit))
+		rows, err := db.db.QueryContext(ctx, listPagesQuery, after, softLimit(limit))
 		if err != nil {
 			yield(nil, fmt.Errorf("query: %v", err))
 			return
 		}
 		defer rows.Close()
 
-		pages := make([]Page, 0, DBSliceSize)
+		s := newPageSlicer(db.sliceBytes)
+		defer s.release()
+		var title, text sql.RawBytes
 		for rows.Next() {
 			var p Page
-			err := rows.Scan(&p.ID, &p.UpdatedAt, &p.Title, &p.Text)
+			err := rows.Scan(&p.ID, &p.UpdatedAt, &title, &text)
 			if err != nil {
 				yield(nil, fmt.Errorf("scan: %v", err))
 				return
 			}
+			p.Title, p.Text = s.copy(title), s.copy(text)
 
-			pages = append(pages, p)
-			if len(pages) < DBSliceSize {
+			if !s.add(p) {
 				continue
 			}
-
-			if !yield(pages, err) {
+			if !yield(s.pages(), err) {
 				return
 			}
-			pages = pages[:0]
+			s.reset()
 		}
 
 		if err := rows.Err(); err != nil {
@@ -137,12 +278,83 @@ func (db *DB) StreamPageSlice(ctx context.Context, limit int) func(func([]Page,
 			return
 		}
 
-		if len(pages) > 0 {
-			yield(pages, nil)
+		if len(s.pages()) > 0 {
+			yield(s.pages(), nil)
 		}
 	}
 }
 
+// minPageArena is the initial size of the arena of a slice of pages.
+const minPageArena = 64 << 10
+
+// pageSliceBuf holds a slice of pages and the arena storing their strings.
+type pageSliceBuf struct {
+	pages []Page
+	arena []byte
+}
+
+// pageSlicePool reuses the slices of pages and their arenas between requests.
+var pageSlicePool = sync.Pool{New: func() any { return new(pageSliceBuf) }}
+
+// pageSlicer cuts a stream of pages into slices of at most [DBSliceSize]
+// pages, a slice being full once the size of its titles and texts reaches
+// bytes.
+type pageSlicer struct {
+	bytes int
+	buf   *pageSliceBuf
+	size  int
+}
+
+func newPageSlicer(bytes int) *pageSlicer {
+	return &pageSlicer{bytes: bytes, buf: pageSlicePool.Get().(*pageSliceBuf)}
+}
+
+// add appends p to the slice and reports whether the slice is full.
+func (s *pageSlicer) add(p Page) bool {
+	s.buf.pages = append(s.buf.pages, p)
+	s.size += len(p.Title) + len(p.Text)
+	return len(s.buf.pages) >= DBSliceSize || s.size >= s.bytes
+}
+
+// copy copies b into the arena of the slice, the string is valid until the
+// slice is reset. A full arena is replaced by a larger one, the strings
+// already returned keep the previous one.
+func (s *pageSlicer) copy(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	arena := s.buf.arena
+	if cap(arena)-len(arena) < len(b) {
+		arena = make([]byte, 0, max(2*cap(arena), len(b), minPageArena))
+	}
+	off := len(arena)
+	arena = append(arena, b...)
+	s.buf.arena = arena
+	return unsafe.String(&arena[off], len(b))
+}
+
+// pages returns the current slice.
+func (s *pageSlicer) pages() []Page {
+	return s.buf.pages
+}
+
+// reset empties the slice and its arena, the strings of its pages are
+// overwritten by the next pages.
+func (s *pageSlicer) reset() {
+	clear(s.buf.pages)
+	s.buf.pages = s.buf.pages[:0]
+	s.buf.arena = s.buf.arena[:0]
+	s.size = 0
+}
+
+// release returns the slice and its arena to the pool, s must not be used
+// afterwards.
+func (s *pageSlicer) release() {
+	s.reset()
+	pageSlicePool.Put(s.buf)
+	s.buf = nil
+}
+
 // softLimit changes the zero value of limit into -1, allowing SQLite to return
 // the full dataset if the limit is unset.
 func softLimit(limit int) int {
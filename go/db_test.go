@@ -2,13 +2,115 @@ package main
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/y1w5/stream/db/synth"
 )
 
 const dbPath = "stream.db"
 
+// synthPages is the number of pages of the synthetic database.
+const synthPages = 5000
+
+var synthDir string
+
+// synthDBPath generates the synthetic database once per test binary.
+var synthDBPath = sync.OnceValues(func() (string, error) {
+	var err error
+	synthDir, err = os.MkdirTemp("", "stream-test-")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(synthDir, "stream.db")
+
+	opts := synth.DefaultOptions()
+	opts.Pages = synthPages
+	if err := synth.WriteDB(path, opts); err != nil {
+		return "", err
+	}
+	return path, nil
+})
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if synthDir != "" {
+		_ = os.RemoveAll(synthDir)
+	}
+	os.Exit(code)
+}
+
+// testDBPath returns dbPath if the Wikipedia database exists, otherwise it
+// returns the path to a synthetic database.
+func testDBPath(tb testing.TB) string {
+	tb.Helper()
+
+	_, err := os.Stat(dbPath)
+	if err == nil {
+		return dbPath
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		tb.Fatalf("stat %v: %v", dbPath, err)
+	}
+
+	path, err := synthDBPath()
+	if err != nil {
+		tb.Fatalf("generate synthetic db: %v", err)
+	}
+	return path
+}
+
+func TestStreamPageSlice(t *testing.T) {
+	db, err := NewDB(testDBPath(t))
+	if err != nil {
+		t.Fatalf("new DB: %v", err)
+	}
+	defer db.Close()
+	db.sliceBytes = 64 << 10
+
+	ctx := context.Background()
+	var want []Page
+	for p, err := range db.StreamPages(ctx, 0) {
+		if err != nil {
+			t.Fatalf("stream pages: %v", err)
+		}
+		want = append(want, p)
+	}
+
+	// The titles and texts are read from the arena of the slice.
+	var got []Page
+	var slices int
+	for pages, err := range db.StreamPageSlice(ctx, 0) {
+		if err != nil {
+			t.Fatalf("stream page slice: %v", err)
+		}
+		var size int
+		for i, p := range pages {
+			// Only the last page of a slice crosses the budget.
+			if size >= db.sliceBytes {
+				t.Fatalf("slice %d: page %d over the budget", slices, i)
+			}
+			size += len(p.Title) + len(p.Text)
+			p.Title, p.Text = strings.Clone(p.Title), strings.Clone(p.Text)
+			got = append(got, p)
+		}
+		slices++
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected pages: expects %d pages, got %d", len(want), len(got))
+	}
+	if slices < 2 {
+		t.Fatalf("expects several slices, got %d", slices)
+	}
+}
+
 func BenchmarkDBListPages(b *testing.B) {
-	db, err := NewDB(dbPath)
+	db, err := NewDB(testDBPath(b))
 	if err != nil {
 		b.Fatalf("new DB: %v", err)
 	}
@@ -26,7 +128,7 @@ func BenchmarkDBListPages(b *testing.B) {
 }
 
 func BenchmarkDBStreamPages(b *testing.B) {
-	db, err := NewDB(dbPath)
+	db, err := NewDB(testDBPath(b))
 	if err != nil {
 		b.Fatalf("new DB: %v", err)
 	}
@@ -45,7 +147,7 @@ func BenchmarkDBStreamPages(b *testing.B) {
 }
 
 func BenchmarkDBStreamPageSlice(b *testing.B) {
-	db, err := NewDB(dbPath)
+	db, err := NewDB(testDBPath(b))
 	if err != nil {
 		b.Fatalf("new DB: %v", err)
 	}
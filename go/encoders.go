@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// encoderFunc is a strategy writing pages into the response.
+type encoderFunc func(s *Stream, w http.ResponseWriter, r *http.Request)
+
+// defaultEncoder is the encoder used when the client does not select one.
+const defaultEncoder = "iter"
+
+// listEncoders are the strategies of /pages.list. They buffer all the pages
+// before writing the response.
+var listEncoders = map[string]encoderFunc{
+	"iter":  (*Stream).listPages,
+	"std":   (*Stream).listPagesStd,
+	"exp":   (*Stream).listPagesExp,
+	"slice": (*Stream).listPagesSlice,
+}
+
+// streamEncoders are the strategies of /pages.stream. They write the pages as
+// soon as they are read from the database. Only the default encoder supports
+// the format, flush and compression options.
+var streamEncoders = map[string]encoderFunc{
+	"iter":      (*Stream).streamPages,
+	"std":       (*Stream).streamPagesStd,
+	"slice":     (*Stream).streamPagesSlice,
+	"marshaler": (*Stream).streamPagesWithMarshaler,
+}
+
+// handleEncoders registers the encoders under pattern and pattern.{name}. The
+// encoder of pattern is selected with the encoder query parameter.
+func (s *Stream) handleEncoders(mux *http.ServeMux, pattern string, encoders map[string]encoderFunc) {
+	mux.HandleFunc(pattern, allowMethods(s.withCollection(s.track(s.selectEncoder(encoders))), streamMethods...))
+	for name, fn := range encoders {
+		mux.HandleFunc(pattern+"."+name, allowMethods(s.withCollection(s.track(func(w http.ResponseWriter, r *http.Request) {
+			fn(s, w, r)
+		})), streamMethods...))
+	}
+}
+
+// selectEncoder returns a handler dispatching the request to the encoder
+// named by the encoder query parameter.
+func (s *Stream) selectEncoder(encoders map[string]encoderFunc) http.HandlerFunc {
+	names := slices.Sorted(maps.Keys(encoders))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("encoder")
+		if name == "" {
+			name = defaultEncoder
+		}
+
+		fn, ok := encoders[name]
+		if !ok {
+			err := invalidParameter("encoder", name)
+			err.message += ", expects one of " + strings.Join(names, ", ")
+			writeError(w, err)
+			return
+		}
+		fn(s, w, r)
+	}
+}
+
+// listPagesStd lists all pages from the database and write the JSON using
+// `encoding/json` from the standard library.
+func (s *Stream) listPagesStd(w http.ResponseWriter, r *http.Request) {
+	var pages []Page
+	var held int64
+	info := streamFromContext(r.Context())
+	defer func() { info.shrink(held) }()
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, err := s.parseLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pages, err = svc.ListPages(r.Context(), limit)
+	if err != nil {
+		s.logger.Error("fail to execute handler", "err", err)
+		goto encode_err
+	}
+	info.addPages(len(pages))
+	held = pagesMemory(pages)
+
+	// The encoder marshals the whole array before writing it, its buffer
+	// is about the size of the pages.
+	err = info.grow(held)
+	held *= 2
+	if err != nil {
+		s.logger.Warn("abort request over its memory budget", "route", r.URL.Path, "budget", info.budget)
+		goto encode_err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(pages)
+	return
+
+encode_err:
+	writeError(w, err)
+}
+
+// streamPagesStd streams pages from the database and write the JSON using
+// `encoding/json` from the standard library.
+func (s *Stream) streamPagesStd(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, err := s.parseLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	e := json.NewEncoder(w)
+	for p, err := range countPages(r.Context(), svc.StreamPages(r.Context(), limit)) {
+		if err != nil {
+			s.logger.Error("fail to stream pages", "err", err)
+			return
+		}
+		err = e.Encode(p)
+		if err != nil {
+			s.logger.Error("fail to encode JSON", "err", err)
+			return
+		}
+	}
+}
+
+// listPagesExp lists all pages from the database and writes the JSON using
+// the experimental `encoding/json/v2`.
+func (s *Stream) listPagesExp(w http.ResponseWriter, r *http.Request) {
+	var pages []Page
+	info := streamFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, err := s.parseLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pages, err = svc.ListPages(r.Context(), limit)
+	if err != nil {
+		s.logger.Error("fail to execute handler", "err", err)
+		goto encode_err
+	}
+	info.addPages(len(pages))
+	defer info.shrink(pagesMemory(pages))
+
+	w.WriteHeader(http.StatusOK)
+	_ = jsonv2.MarshalEncode(jsontext.NewEncoder(w), pages)
+	return
+
+encode_err:
+	writeError(w, err)
+}
+
+// listPagesSlice lists pages as slices, aggregates them and write the
+// JSON using the experimental `encoding/json/v2`.
+func (s *Stream) listPagesSlice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, err := s.parseLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	batches := svc.StreamPageSlice(r.Context(), limit)
+	s.writeList(w, r, func(yield func(Page, error) bool) {
+		for pages, err := range batches {
+			if err != nil {
+				yield(Page{}, err)
+				return
+			}
+			streamFromContext(r.Context()).addPages(len(pages))
+			for _, p := range pages {
+				// The list holds the pages after the slice is reused.
+				p.Title, p.Text = strings.Clone(p.Title), strings.Clone(p.Text)
+				if !yield(p, nil) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// streamPagesSlice streams pages as slices and write the JSON using
+// the experimental `encoding/json/v2`.
+func (s *Stream) streamPagesSlice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, err := s.parseLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	e := jsontext.NewEncoder(w)
+	err = e.WriteToken(jsontext.ArrayStart)
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+
+	for pages, err := range svc.StreamPageSlice(r.Context(), limit) {
+		if err != nil {
+			s.logger.Error("fail to stream pages", "err", err)
+			return
+		}
+		streamFromContext(r.Context()).addPages(len(pages))
+		for _, p := range pages {
+			err = jsonv2.MarshalEncode(e, p)
+			if err != nil {
+				s.logger.Error("fail to encode JSON", "err", err)
+				return
+			}
+		}
+	}
+
+	err = e.WriteToken(jsontext.ArrayEnd)
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+}
+
+// marshalPage writes p field by field, with the same output as the default
+// marshaling of [Page].
+func marshalPage(e *jsontext.Encoder, p *Page, opts jsonv2.Options) error {
+	for _, t := range []jsontext.Token{
+		jsontext.ObjectStart,
+		jsontext.String("ID"),
+		jsontext.Int(p.ID),
+		jsontext.String("UpdatedAt"),
+		jsontext.String(p.UpdatedAt.Format(time.RFC3339Nano)),
+		jsontext.String("Title"),
+		jsontext.String(p.Title),
+		jsontext.String("Text"),
+		jsontext.String(p.Text),
+		jsontext.ObjectEnd,
+	} {
+		if err := e.WriteToken(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Stream) streamPagesWithMarshaler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, err := s.parseLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	e := jsontext.NewEncoder(w)
+	err = e.WriteToken(jsontext.ArrayStart)
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+
+	opts := jsonv2.WithMarshalers(jsonv2.MarshalFuncV2(marshalPage))
+	for p, err := range countPages(r.Context(), svc.StreamPages(r.Context(), limit)) {
+		if err != nil {
+			s.logger.Error("fail to stream pages", "err", err)
+			return
+		}
+		err = jsonv2.MarshalEncode(e, &p, opts)
+		if err != nil {
+			s.logger.Error("fail to encode JSON", "err", err)
+			return
+		}
+	}
+
+	err = e.WriteToken(jsontext.ArrayEnd)
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+
+	"github.com/y1w5/stream/db/synth"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+const goldenPages = "testdata/pages.golden.json"
+
+// newTestStream creates a Stream over a small synthetic database with all the
+// wikitext features, including characters escaped differently by the JSON
+// libraries.
+func newTestStream(t *testing.T) *Stream {
+	t.Helper()
+
+	opts := synth.DefaultOptions()
+	opts.Pages = 20
+	opts.MaxTextSize = 1000
+	path := filepath.Join(t.TempDir(), "stream.db")
+	if err := synth.WriteDB(path, opts); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	s, err := NewStream(NewStreamParams{
+		DB:     path,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	t.Cleanup(func() { _ = closeCollections(s.collections) })
+	return s
+}
+
+// goldenTimes are the times of the pages added after the synthetic pages by
+// [newGoldenStream]. The synthetic times are whole hours in UTC, these ones
+// check that the encoders keep the fractional seconds and the offsets.
+var goldenTimes = []string{
+	"2024-03-10 12:30:45.123456789+02:00",
+	"2024-03-10 12:30:45.5-05:30",
+	"2024-03-10 12:30:45.000001",
+}
+
+// newGoldenStream creates the Stream of the golden file: a test stream with a
+// page per time of goldenTimes.
+func newGoldenStream(t *testing.T) *Stream {
+	t.Helper()
+
+	s := newTestStream(t)
+	db := s.collections[defaultCollection].db()
+	for i, updatedAt := range goldenTimes {
+		_, err := db.db.Exec(`INSERT INTO pages (id, updated_at, title, "text") VALUES ((SELECT max(id) + 1 FROM pages), ?, ?, ?)`,
+			updatedAt, fmt.Sprintf("Time %d", i+1), "Updated at "+updatedAt+".")
+		if err != nil {
+			t.Fatalf("insert page: %v", err)
+		}
+	}
+	return s
+}
+
+// diffPages compares the pages decoded from an encoder output to the golden
+// pages. The formats storing UpdatedAt as an instant lose its offset and may
+// round it: with a precision, the times are compared as instants truncated to
+// precision.
+func diffPages(got, want []map[string]any, precision time.Duration) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("unexpected page count: expects=%d got=%d", len(want), len(got))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if precision > 0 {
+			g, w = maps.Clone(g), maps.Clone(w)
+			for _, p := range []map[string]any{g, w} {
+				v, _ := p["UpdatedAt"].(string)
+				updatedAt, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return fmt.Errorf("page %d: %v", i, err)
+				}
+				p["UpdatedAt"] = updatedAt.Truncate(precision).UTC().Format(time.RFC3339Nano)
+			}
+		}
+		if !reflect.DeepEqual(g, w) {
+			return fmt.Errorf("page %d differs:\nexpects=%v\ngot=%v", i, w, g)
+		}
+	}
+	return nil
+}
+
+// decodePages decodes a JSON array or a stream of JSON values into generic
+// objects, keeping the field names and the raw time format.
+func decodePages(body []byte) ([]map[string]any, error) {
+	d := jsontext.NewDecoder(bytes.NewReader(body))
+	array := d.PeekKind() == '['
+	if array {
+		if _, err := d.ReadToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	pages := []map[string]any{}
+	for {
+		kind := d.PeekKind()
+		if kind == ']' || kind == 0 {
+			break
+		}
+		var p map[string]any
+		if err := jsonv2.UnmarshalDecode(d, &p); err != nil {
+			return nil, err
+		}
+		pages = append(pages, p)
+	}
+
+	if array {
+		if _, err := d.ReadToken(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := d.ReadToken(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unexpected trailing data: %v", err)
+	}
+	return pages, nil
+}
+
+func TestEncodersEquivalence(t *testing.T) {
+	s := newGoldenStream(t)
+
+	type variant struct {
+		name    string
+		handler http.HandlerFunc
+		query   string
+		decode  func([]byte) ([]map[string]any, error)
+		// precision is the precision of the formats storing instants, see
+		// [diffPages].
+		precision time.Duration
+	}
+	var variants []variant
+	for _, name := range slices.Sorted(maps.Keys(listEncoders)) {
+		fn := listEncoders[name]
+		variants = append(variants, variant{name: "list." + name, handler: func(w http.ResponseWriter, r *http.Request) { fn(s, w, r) }})
+	}
+	for _, name := range slices.Sorted(maps.Keys(streamEncoders)) {
+		fn := streamEncoders[name]
+		variants = append(variants, variant{name: "stream." + name, handler: func(w http.ResponseWriter, r *http.Request) { fn(s, w, r) }})
+	}
+	variants = append(variants, variant{name: "stream.ndjson", handler: s.streamPages, query: "?format=ndjson"})
+	variants = append(variants, variant{name: "stream.arrow", handler: s.streamPages, query: "?format=arrow", decode: decodeArrow, precision: time.Nanosecond})
+	variants = append(variants, variant{name: "stream.msgpack", handler: s.streamPages, query: "?format=msgpack", decode: decodeMsgPack, precision: time.Nanosecond})
+	variants = append(variants, variant{name: "stream.cbor", handler: s.streamPages, query: "?format=cbor", decode: decodeCBOR})
+	variants = append(variants, variant{name: "stream.csv", handler: s.streamPages, query: "?format=csv", decode: decodeCSV})
+	variants = append(variants, variant{name: "stream.tsv", handler: s.streamPages, query: "?format=tsv", decode: decodeTSV})
+	variants = append(variants, variant{name: "parquet", handler: s.parquetPages, decode: decodeParquet, precision: time.Nanosecond})
+
+	if *update {
+		body := jsontext.Value(record(t, s.listPages, ""))
+		if err := body.Indent("", "\t"); err != nil {
+			t.Fatalf("indent: %v", err)
+		}
+		if err := os.WriteFile(goldenPages, append(body, '\n'), 0o644); err != nil {
+			t.Fatalf("write golden: %v", err)
+		}
+	}
+
+	golden, err := os.ReadFile(goldenPages)
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	want, err := decodePages(golden)
+	if err != nil {
+		t.Fatalf("decode golden: %v", err)
+	}
+
+	for _, v := range variants {
+		t.Run(v.name, func(t *testing.T) {
+			decode := decodePages
+			if v.decode != nil {
+				decode = v.decode
+			}
+			got, err := decode(record(t, v.handler, v.query))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if err := diffPages(got, want, v.precision); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func record(t *testing.T, h http.HandlerFunc, query string) []byte {
+	t.Helper()
+
+	resp := httptest.NewRecorder()
+	h(resp, httptest.NewRequest("GET", "/"+query, nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("unexpected status: expects=200 got=%d", resp.Code)
+	}
+	return resp.Body.Bytes()
+}
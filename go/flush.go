@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// flushBufferSize is the size of the buffer placed in front of the response.
+const flushBufferSize = 32 << 10
+
+// FlushPolicy controls when a streaming response is flushed to the client.
+//
+// Each rule is disabled when its value is zero. A zero FlushPolicy never
+// flushes explicitly and leaves the decision to net/http.
+type FlushPolicy struct {
+	// Pages flushes the response every N pages.
+	Pages int
+	// Bytes flushes the response every N bytes.
+	Bytes int
+	// Interval flushes the response when the last flush is older than
+	// Interval, including while the stream waits for the next page.
+	Interval time.Duration
+}
+
+// IsZero reports whether all the rules of the policy are disabled.
+func (p FlushPolicy) IsZero() bool {
+	return p.Pages == 0 && p.Bytes == 0 && p.Interval == 0
+}
+
+// parseFlushPolicy overrides the fields of def with the flush_pages,
+// flush_bytes and flush_ms query parameters.
+func parseFlushPolicy(query url.Values, def FlushPolicy) (FlushPolicy, error) {
+	p := def
+	for _, param := range []struct {
+		name string
+		set  func(int)
+	}{
+		{"flush_pages", func(v int) { p.Pages = v }},
+		{"flush_bytes", func(v int) { p.Bytes = v }},
+		{"flush_ms", func(v int) { p.Interval = time.Duration(v) * time.Millisecond }},
+	} {
+		tmp := query.Get(param.name)
+		if tmp == "" {
+			continue
+		}
+		v, err := strconv.Atoi(tmp)
+		if err != nil || v < 0 {
+			return FlushPolicy{}, invalidParameter(param.name, tmp)
+		}
+		param.set(v)
+	}
+	return p, nil
+}
+
+// SlowClientPolicy protects the server from clients draining the response
+// slowly. A stalled client holds a database cursor and a goroutine.
+//
+// Each rule is disabled when its value is zero.
+type SlowClientPolicy struct {
+	// WriteTimeout is the deadline of each chunk written to the client. It is
+	// renewed before each chunk.
+	WriteTimeout time.Duration
+	// MinRate is the minimum rate, in bytes per second, at which the client
+	// must drain the response. The rate is measured on the time spent
+	// writing, the time spent reading the database is not counted.
+	MinRate int
+	// Grace is the writing time before MinRate is enforced.
+	Grace time.Duration
+}
+
+// errSlowClient is returned when a client drains the response below
+// [SlowClientPolicy.MinRate].
+var errSlowClient = errors.New("client is too slow")
+
+// isSlowClient reports whether err was caused by a slow client.
+func isSlowClient(err error) bool {
+	return errors.Is(err, errSlowClient) || errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// writerFunc is an adapter allowing to use a function as an [io.Writer].
+type writerFunc func([]byte) (int, error)
+
+// Write implements [io.Writer].
+func (fn writerFunc) Write(b []byte) (int, error) {
+	return fn(b)
+}
+
+var flushBufferPool = sync.Pool{
+	New: func() any { return bufio.NewWriterSize(nil, flushBufferSize) },
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// flushWriter buffers a response and flushes it according to a [FlushPolicy].
+// It enforces a [SlowClientPolicy] on each chunk written to the client.
+//
+// It relies on [http.ResponseController] to reach the [http.Flusher] and the
+// connection deadlines behind wrappers such as the logger middleware.
+//
+// The interval of the policy is driven by a timer flushing the response from
+// its own goroutine: mu serializes it with the writes of the handler.
+type flushWriter struct {
+	mu     sync.Mutex
+	policy FlushPolicy
+	slow   SlowClientPolicy
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	buf    *bufio.Writer
+	gz     *gzip.Writer
+
+	pages     int
+	bytes     int
+	lastFlush time.Time
+	// timer flushes the response once the interval elapsed, err holds the
+	// error of the flush until the next call of the handler.
+	timer *time.Timer
+	err   error
+
+	sent    int
+	writing time.Duration
+
+	// info holds the memory of the buffers in the request.
+	info *streamInfo
+	held int64
+}
+
+// newFlushWriter creates a flushWriter. [flushWriter.Close] must be called to
+// write the remaining data and release the buffer.
+func newFlushWriter(w http.ResponseWriter, policy FlushPolicy, slow SlowClientPolicy) *flushWriter {
+	f := &flushWriter{
+		policy:    policy,
+		slow:      slow,
+		w:         w,
+		rc:        http.NewResponseController(w),
+		lastFlush: time.Now(),
+	}
+	f.buf = flushBufferPool.Get().(*bufio.Writer)
+	f.buf.Reset(writerFunc(f.writeChunk))
+	if policy.Interval > 0 {
+		f.timer = time.AfterFunc(policy.Interval, f.tick)
+	}
+	return f
+}
+
+// tick flushes the response if nothing was flushed during the interval, and
+// schedules the next check.
+func (f *flushWriter) tick() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.buf == nil || f.err != nil {
+		return
+	}
+
+	wait := f.policy.Interval - time.Since(f.lastFlush)
+	if wait <= 0 {
+		if f.bytes > 0 {
+			f.err = f.flush()
+		} else {
+			f.lastFlush = time.Now()
+		}
+		wait = f.policy.Interval
+	}
+	f.timer.Reset(wait)
+}
+
+// track holds the memory of the buffers in the request of info, info may be
+// nil. The streamed responses are not bounded by the budget: their buffers
+// have a fixed size.
+func (f *flushWriter) track(info *streamInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.info = info
+	f.hold(flushBufferSize)
+	if f.gz != nil {
+		f.hold(gzipWriterMemory)
+	}
+}
+
+// hold adds n bytes to the memory held by the buffers.
+func (f *flushWriter) hold(n int64) {
+	if f.info != nil {
+		f.held += n
+		_ = f.info.grow(n)
+	}
+}
+
+// writeChunk writes a chunk of the buffer to the client.
+func (f *flushWriter) writeChunk(b []byte) (int, error) {
+	start := f.renewDeadline()
+	n, err := f.w.Write(b)
+	f.account(start, n)
+	if err != nil {
+		return n, err
+	}
+	return n, f.checkRate()
+}
+
+// renewDeadline renews the write deadline and returns the current time.
+func (f *flushWriter) renewDeadline() time.Time {
+	now := time.Now()
+	if f.slow.WriteTimeout > 0 {
+		// Recorders used in tests do not support deadlines.
+		_ = f.rc.SetWriteDeadline(now.Add(f.slow.WriteTimeout))
+	}
+	return now
+}
+
+// account records n bytes sent since start.
+func (f *flushWriter) account(start time.Time, n int) {
+	f.sent += n
+	f.writing += time.Since(start)
+}
+
+// checkRate fails if the client drains the response below the minimum rate.
+func (f *flushWriter) checkRate() error {
+	if f.slow.MinRate == 0 || f.writing < max(f.slow.Grace, time.Second) {
+		return nil
+	}
+	if float64(f.sent)/f.writing.Seconds() < float64(f.slow.MinRate) {
+		return fmt.Errorf("%w: %d bytes in %v", errSlowClient, f.sent, f.writing.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// Gzip compresses the data written after the call. The policy applies to the
+// uncompressed data.
+func (f *flushWriter) Gzip() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gz = gzipWriterPool.Get().(*gzip.Writer)
+	f.gz.Reset(f.buf)
+	f.hold(gzipWriterMemory)
+}
+
+// Write implements [io.Writer].
+func (f *flushWriter) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return 0, f.err
+	}
+	var w io.Writer = f.buf
+	if f.gz != nil {
+		w = f.gz
+	}
+	n, err := w.Write(b)
+	f.bytes += n
+	return n, err
+}
+
+// Page records that a page was written and flushes the response if the
+// policy requires it.
+func (f *flushWriter) Page() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.pages++
+
+	p := f.policy
+	switch {
+	case p.Pages > 0 && f.pages >= p.Pages:
+	case p.Bytes > 0 && f.bytes >= p.Bytes:
+	case p.Interval > 0 && time.Since(f.lastFlush) >= p.Interval:
+	default:
+		return nil
+	}
+	return f.flush()
+}
+
+// Flush writes the buffered data and flushes the response.
+func (f *flushWriter) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	return f.flush()
+}
+
+// flush implements [flushWriter.Flush], f.mu must be held.
+func (f *flushWriter) flush() error {
+	f.pages, f.bytes, f.lastFlush = 0, 0, time.Now()
+
+	if f.gz != nil {
+		if err := f.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := f.buf.Flush(); err != nil {
+		return err
+	}
+
+	start := f.renewDeadline()
+	err := f.rc.Flush()
+	f.account(start, 0)
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return f.checkRate()
+}
+
+// Close writes the buffered data and releases the buffers.
+func (f *flushWriter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+
+	errs := []error{f.err}
+	if f.gz != nil {
+		errs = append(errs, f.gz.Close())
+		f.gz.Reset(io.Discard)
+		gzipWriterPool.Put(f.gz)
+		f.gz = nil
+	}
+
+	errs = append(errs, f.buf.Flush())
+	f.buf.Reset(io.Discard)
+	flushBufferPool.Put(f.buf)
+	f.buf = nil
+	f.info.shrink(f.held)
+	f.held = 0
+	return errors.Join(errs...)
+}
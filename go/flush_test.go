@@ -0,0 +1,181 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+func TestParseFlushPolicy(t *testing.T) {
+	def := FlushPolicy{Pages: 10}
+
+	tests := []struct {
+		query   string
+		want    FlushPolicy
+		wantErr bool
+	}{
+		{query: "", want: def},
+		{query: "flush_pages=1", want: FlushPolicy{Pages: 1}},
+		{query: "flush_bytes=4096&flush_ms=50", want: FlushPolicy{Pages: 10, Bytes: 4096, Interval: 50 * time.Millisecond}},
+		{query: "flush_pages=abc", wantErr: true},
+		{query: "flush_bytes=-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("parse query %q: %v", tt.query, err)
+		}
+		got, err := parseFlushPolicy(query, def)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%q: unexpected error: %v", tt.query, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%q: unexpected policy: expects=%+v got=%+v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestFlushWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	fw := newFlushWriter(w, FlushPolicy{Pages: 2}, SlowClientPolicy{})
+
+	_, _ = fw.Write([]byte("a"))
+	if err := fw.Page(); err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	if w.Flushed || w.Body.Len() != 0 {
+		t.Fatalf("unexpected flush after 1 page")
+	}
+
+	_, _ = fw.Write([]byte("b"))
+	if err := fw.Page(); err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	if !w.Flushed || w.Body.String() != "ab" {
+		t.Fatalf("expects flush after 2 pages: flushed=%v body=%q", w.Flushed, w.Body.String())
+	}
+
+	_, _ = fw.Write([]byte("c"))
+	if err := fw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if w.Body.String() != "abc" {
+		t.Fatalf("unexpected body: %q", w.Body.String())
+	}
+}
+
+func TestFlushWriterSlowClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		slow    SlowClientPolicy
+		sent    int
+		writing time.Duration
+		wantErr bool
+	}{
+		{name: "disabled", slow: SlowClientPolicy{}, sent: 1, writing: time.Minute},
+		{name: "grace", slow: SlowClientPolicy{MinRate: 1000, Grace: 5 * time.Second}, sent: 1, writing: 2 * time.Second},
+		{name: "fast", slow: SlowClientPolicy{MinRate: 1000}, sent: 5000, writing: 2 * time.Second},
+		{name: "slow", slow: SlowClientPolicy{MinRate: 1000}, sent: 500, writing: 2 * time.Second, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		fw := newFlushWriter(httptest.NewRecorder(), FlushPolicy{}, tt.slow)
+		fw.sent, fw.writing = tt.sent, tt.writing
+		err := fw.checkRate()
+		if (err != nil) != tt.wantErr || (err != nil && !isSlowClient(err)) {
+			t.Fatalf("%v: unexpected error: %v", tt.name, err)
+		}
+		_ = fw.Close()
+	}
+}
+
+func TestFlushPagesOverConnection(t *testing.T) {
+	const limit = 5
+
+	// The pages after the first are only read once the client received the
+	// previous one.
+	s := newTestStream(t)
+	received := make(chan struct{}, limit)
+	var stalled atomic.Bool
+	s.transforms = []PageTransform{func(p *Page) {
+		if p.ID == 1 {
+			return
+		}
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			stalled.Store(true)
+		}
+	}}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	for _, f := range []format{formatJSON, formatNDJSON} {
+		resp, err := http.Get(srv.URL + "/pages.stream?flush_pages=1&limit=" + strconv.Itoa(limit) + "&format=" + string(f))
+		if err != nil {
+			t.Fatalf("%v: get: %v", f, err)
+		}
+		d := jsontext.NewDecoder(resp.Body)
+		if f == formatJSON {
+			if _, err := d.ReadToken(); err != nil {
+				t.Fatalf("%v: read array start: %v", f, err)
+			}
+		}
+		for i := range limit {
+			if _, err := d.ReadValue(); err != nil {
+				t.Fatalf("%v: read page: %v", f, err)
+			}
+			if i < limit-1 {
+				received <- struct{}{}
+			}
+		}
+		_ = resp.Body.Close()
+		if stalled.Load() {
+			t.Fatalf("%v: a page was not flushed before the next one", f)
+		}
+	}
+}
+
+func TestFlushIntervalIdleStream(t *testing.T) {
+	// The second page is only read once the client received the first one:
+	// the interval must flush the stream while it waits.
+	s := newTestStream(t)
+	received := make(chan struct{})
+	var stalled atomic.Bool
+	s.transforms = []PageTransform{func(p *Page) {
+		if p.ID != 2 {
+			return
+		}
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			stalled.Store(true)
+		}
+	}}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/pages.stream?format=ndjson&flush_ms=20&limit=2")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	d := jsontext.NewDecoder(resp.Body)
+	if _, err := d.ReadValue(); err != nil {
+		t.Fatalf("read page: %v", err)
+	}
+	close(received)
+	if _, err := d.ReadValue(); err != nil {
+		t.Fatalf("read page: %v", err)
+	}
+	if stalled.Load() {
+		t.Fatalf("the first page was not flushed while the stream was idle")
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/y1w5/stream/go/pagespb"
+)
+
+const (
+	// grpcBatchSize is the default number of pages of a batch.
+	grpcBatchSize = 100
+	// grpcBatchBytes is the size after which a batch is sent even if it is
+	// not full. It keeps the messages under the 4MB limit of the clients.
+	grpcBatchBytes = 1 << 20
+)
+
+// grpcServer implements the Pages gRPC service over the collections of a
+// [Stream].
+type grpcServer struct {
+	pagespb.UnimplementedPagesServer
+	s *Stream
+}
+
+// newGRPCServer creates the gRPC server of s.
+func newGRPCServer(s *Stream) *grpc.Server {
+	srv := grpc.NewServer(grpc.StreamInterceptor(s.logStream))
+	pagespb.RegisterPagesServer(srv, &grpcServer{s: s})
+	return srv
+}
+
+// serveGRPC serves the gRPC server in the background, reporting its error
+// on s.errChan.
+func (s *Stream) serveGRPC() {
+	go func() {
+		lis, err := net.Listen("tcp", s.grpcBind)
+		if err != nil {
+			s.errChan <- fmt.Errorf("listen: %v", err)
+			return
+		}
+		s.logger.Info("listening on " + s.grpcBind + " (gRPC)")
+		err = s.grpc.Serve(lis)
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			s.errChan <- fmt.Errorf("listen: %v", err)
+			return
+		}
+		s.errChan <- nil
+	}()
+}
+
+// stopGRPC waits for the running gRPC streams to finish until ctx is done,
+// then stops them.
+func (s *Stream) stopGRPC(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpc.Stop()
+		<-done
+	}
+}
+
+// logStream logs the gRPC streams like the HTTP logger middleware.
+func (s *Stream) logStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	s.logger.Info("incoming stream",
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start).Round(time.Millisecond),
+	)
+	return err
+}
+
+// StreamPages implements [pagespb.PagesServer].
+func (g *grpcServer) StreamPages(req *pagespb.StreamRequest, stream grpc.ServerStreamingServer[pagespb.Page]) error {
+	gs, err := g.begin(stream.Context(), req, pagespb.Pages_StreamPages_FullMethodName)
+	if err != nil {
+		return err
+	}
+	defer gs.done()
+
+	m := &pagespb.Page{UpdatedAt: &timestamppb.Timestamp{}}
+	for p, err := range gs.svc.StreamPagesAfter(gs.ctx, req.After, gs.limit) {
+		if err != nil {
+			return g.fail(gs.ctx, err)
+		}
+		pageToProto(p, m)
+		if err := stream.Send(m); err != nil {
+			return err
+		}
+		gs.info.addPages(1)
+		gs.info.bytes.Add(int64(proto.Size(m)))
+	}
+	return nil
+}
+
+// StreamPageBatches implements [pagespb.PagesServer].
+func (g *grpcServer) StreamPageBatches(req *pagespb.StreamRequest, stream grpc.ServerStreamingServer[pagespb.PageBatch]) error {
+	gs, err := g.begin(stream.Context(), req, pagespb.Pages_StreamPageBatches_FullMethodName)
+	if err != nil {
+		return err
+	}
+	defer gs.done()
+
+	size := int(req.BatchSize)
+	switch {
+	case size < 0 || size > DBSliceSize:
+		return status.Errorf(codes.InvalidArgument, "batch size must be between 1 and %d", DBSliceSize)
+	case size == 0:
+		size = grpcBatchSize
+	}
+
+	// The messages are reused between batches, Send marshals them before
+	// returning.
+	batch := &pagespb.PageBatch{Pages: make([]*pagespb.Page, 0, size)}
+	pool := make([]*pagespb.Page, size)
+	for i := range pool {
+		pool[i] = &pagespb.Page{UpdatedAt: &timestamppb.Timestamp{}}
+	}
+	var bytes int
+	send := func() error {
+		if err := stream.Send(batch); err != nil {
+			return err
+		}
+		gs.info.addPages(len(batch.Pages))
+		gs.info.bytes.Add(int64(proto.Size(batch)))
+		batch.Pages, bytes = batch.Pages[:0], 0
+		return nil
+	}
+
+	for p, err := range gs.svc.StreamPagesAfter(gs.ctx, req.After, gs.limit) {
+		if err != nil {
+			return g.fail(gs.ctx, err)
+		}
+		m := pool[len(batch.Pages)]
+		pageToProto(p, m)
+		batch.Pages = append(batch.Pages, m)
+		bytes += len(p.Title) + len(p.Text)
+		if len(batch.Pages) < size && bytes < grpcBatchBytes {
+			continue
+		}
+		if err := send(); err != nil {
+			return err
+		}
+	}
+	if len(batch.Pages) > 0 {
+		return send()
+	}
+	return nil
+}
+
+// grpcStream is a running stream of the gRPC service.
+type grpcStream struct {
+	ctx   context.Context
+	svc   *Service
+	info  *streamInfo
+	limit int
+	// done releases the database and unregisters the stream.
+	done func()
+}
+
+// begin resolves the collection and the limit of req and registers the
+// stream in the admin listener.
+func (g *grpcServer) begin(ctx context.Context, req *pagespb.StreamRequest, method string) (*grpcStream, error) {
+	c, ok := g.s.collections[req.Collection]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "collection %q not found", req.Collection)
+	}
+	limit, err := g.s.grpcLimit(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if req.After < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid after: %d", req.After)
+	}
+
+	var client string
+	if p, ok := peer.FromContext(ctx); ok {
+		client = p.Addr.String()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ref := c.acquire()
+	info := g.s.streams.add(method, client, g.s.requestMemory, cancel)
+	return &grpcStream{
+		ctx:   ctx,
+		svc:   NewService(ref.db, g.s.transforms, g.s.filters),
+		info:  info,
+		limit: limit,
+		done: func() {
+			g.s.streams.remove(info.id)
+			c.release(ref)
+			cancel()
+		},
+	}, nil
+}
+
+// fail converts a database error into a gRPC status.
+func (g *grpcServer) fail(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	g.s.logger.Error("fail to stream pages", "err", err)
+	return status.Error(codes.Internal, err.Error())
+}
+
+// grpcLimit resolves the limit of a gRPC request like [Stream.parseLimit]. A
+// zero limit is unset and selects the default limit.
+func (s *Stream) grpcLimit(limit int64) (int, error) {
+	switch {
+	case limit == 0 && s.defaultLimit == 0:
+		return s.maxLimit, nil
+	case limit == 0:
+		return s.defaultLimit, nil
+	case limit < 0:
+		return 0, status.Errorf(codes.InvalidArgument, "invalid limit: %d", limit)
+	case s.maxLimit > 0 && limit > int64(s.maxLimit):
+		return 0, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", s.maxLimit)
+	}
+	return int(limit), nil
+}
+
+// pageToProto copies p into m.
+func pageToProto(p Page, m *pagespb.Page) {
+	m.Id = p.ID
+	m.UpdatedAt.Seconds = p.UpdatedAt.Unix()
+	m.UpdatedAt.Nanos = int32(p.UpdatedAt.Nanosecond())
+	m.Title = p.Title
+	m.Text = p.Text
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/y1w5/stream/go/pagespb"
+)
+
+// newGRPCClient serves the gRPC service of s over an in-memory listener.
+func newGRPCClient(t testing.TB, s *Stream) pagespb.PagesClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(s)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return pagespb.NewPagesClient(conn)
+}
+
+// recvPages receives the messages of a stream and converts their pages like
+// [decodePages].
+func recvPages[T any](stream grpc.ServerStreamingClient[T], pages func(*T) []*pagespb.Page) ([]map[string]any, error) {
+	got := []map[string]any{}
+	for {
+		m, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return got, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pages(m) {
+			got = append(got, map[string]any{
+				"ID":        float64(p.Id),
+				"UpdatedAt": p.UpdatedAt.AsTime().Format(time.RFC3339Nano),
+				"Title":     p.Title,
+				"Text":      p.Text,
+			})
+		}
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	s := newGoldenStream(t)
+	c := newGRPCClient(t, s)
+	ctx := context.Background()
+
+	golden, err := os.ReadFile(goldenPages)
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	want, err := decodePages(golden)
+	if err != nil {
+		t.Fatalf("decode golden: %v", err)
+	}
+
+	pages, err := c.StreamPages(ctx, &pagespb.StreamRequest{})
+	if err != nil {
+		t.Fatalf("stream pages: %v", err)
+	}
+	got, err := recvPages(pages, func(p *pagespb.Page) []*pagespb.Page { return []*pagespb.Page{p} })
+	if err != nil {
+		t.Fatalf("recv pages: %v", err)
+	}
+	if err := diffPages(got, want, time.Nanosecond); err != nil {
+		t.Fatalf("StreamPages differs from the golden pages: %v", err)
+	}
+
+	batches, err := c.StreamPageBatches(ctx, &pagespb.StreamRequest{BatchSize: 3})
+	if err != nil {
+		t.Fatalf("stream batches: %v", err)
+	}
+	var count int
+	got, err = recvPages(batches, func(b *pagespb.PageBatch) []*pagespb.Page {
+		if len(b.Pages) > 3 {
+			t.Errorf("unexpected batch size: %d", len(b.Pages))
+		}
+		count++
+		return b.Pages
+	})
+	if err != nil {
+		t.Fatalf("recv batches: %v", err)
+	}
+	if err := diffPages(got, want, time.Nanosecond); err != nil {
+		t.Fatalf("StreamPageBatches differs from the golden pages: %v", err)
+	}
+	if expects := (len(want) + 2) / 3; count != expects {
+		t.Fatalf("unexpected batch count: expects=%d got=%d", expects, count)
+	}
+
+	pages, err = c.StreamPages(ctx, &pagespb.StreamRequest{Limit: 5, After: 10})
+	if err != nil {
+		t.Fatalf("stream pages: %v", err)
+	}
+	got, err = recvPages(pages, func(p *pagespb.Page) []*pagespb.Page { return []*pagespb.Page{p} })
+	if err != nil {
+		t.Fatalf("recv pages: %v", err)
+	}
+	if len(got) != 5 || got[0]["ID"] != float64(11) {
+		t.Fatalf("unexpected pages after 10: %d pages", len(got))
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	s := newTestStream(t)
+	s.maxLimit = 10
+	c := newGRPCClient(t, s)
+
+	tests := []struct {
+		name string
+		req  *pagespb.StreamRequest
+		code codes.Code
+	}{
+		{name: "collection", req: &pagespb.StreamRequest{Collection: "fr"}, code: codes.NotFound},
+		{name: "negative limit", req: &pagespb.StreamRequest{Limit: -1}, code: codes.InvalidArgument},
+		{name: "max limit", req: &pagespb.StreamRequest{Limit: 11}, code: codes.InvalidArgument},
+		{name: "after", req: &pagespb.StreamRequest{After: -1}, code: codes.InvalidArgument},
+		{name: "batch size", req: &pagespb.StreamRequest{BatchSize: DBSliceSize + 1}, code: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := c.StreamPageBatches(context.Background(), tt.req)
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("unexpected code: expects=%v got=%v (%v)", tt.code, got, err)
+			}
+		})
+	}
+}
+
+func BenchmarkGRPC(b *testing.B) {
+	s, err := NewStream(NewStreamParams{
+		DB:     testDBPath(b),
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		b.Fatalf("fail to create stream: %v", err)
+	}
+	b.Cleanup(func() { _ = closeCollections(s.collections) })
+	c := newGRPCClient(b, s)
+
+	b.Run("StreamPages", func(b *testing.B) {
+		for range b.N {
+			stream, err := c.StreamPages(context.Background(), &pagespb.StreamRequest{})
+			if err != nil {
+				b.Fatalf("stream pages: %v", err)
+			}
+			for {
+				if _, err := stream.Recv(); err != nil {
+					if !errors.Is(err, io.EOF) {
+						b.Fatalf("recv: %v", err)
+					}
+					break
+				}
+			}
+		}
+	})
+	b.Run("StreamPageBatches", func(b *testing.B) {
+		for range b.N {
+			stream, err := c.StreamPageBatches(context.Background(), &pagespb.StreamRequest{})
+			if err != nil {
+				b.Fatalf("stream batches: %v", err)
+			}
+			for {
+				if _, err := stream.Recv(); err != nil {
+					if !errors.Is(err, io.EOF) {
+						b.Fatalf("recv: %v", err)
+					}
+					break
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package iterx
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// result is a value or an error sent between goroutines.
+type result[T any] struct {
+	v   T
+	err error
+}
+
+// send sends r to ch unless ctx is cancelled.
+func send[T any](ctx context.Context, ch chan<- T, r T) bool {
+	select {
+	case ch <- r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Merge returns the values of seqs in the order they are read, each sequence
+// being read by its own goroutine. The first error ends the sequence and
+// stops the other goroutines.
+func Merge[T any](ctx context.Context, seqs ...iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		ctx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
+
+		out := make(chan result[T], len(seqs))
+		for _, seq := range seqs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for v, err := range seq {
+					if !send(ctx, out, result[T]{v, err}) || err != nil {
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+
+		for r := range out {
+			if !yield(r.v, r.err) || r.err != nil {
+				return
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// ParallelMap returns the values of seq transformed by fn, calling fn from
+// workers goroutines. The values keep the order of seq, at most 2×workers
+// values are read ahead. An error of seq or fn ends the sequence.
+func ParallelMap[T, U any](ctx context.Context, seq iter.Seq2[T, error], workers int, fn func(context.Context, T) (U, error)) iter.Seq2[U, error] {
+	type job struct {
+		v   T
+		res chan result[U]
+	}
+
+	return func(yield func(U, error) bool) {
+		var zero U
+		ctx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
+
+		// The results are read in the order of the jobs, each job has its
+		// own channel.
+		workers = max(workers, 1)
+		jobs := make(chan job, workers)
+		order := make(chan chan result[U], workers)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(order)
+			defer close(jobs)
+			for v, err := range seq {
+				res := make(chan result[U], 1)
+				if err != nil {
+					res <- result[U]{err: err}
+					send(ctx, order, res)
+					return
+				}
+				if !send(ctx, order, res) || !send(ctx, jobs, job{v, res}) {
+					return
+				}
+			}
+		}()
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range jobs {
+					u, err := fn(ctx, j.v)
+					j.res <- result[U]{u, err}
+				}
+			}()
+		}
+
+		for res := range order {
+			var r result[U]
+			select {
+			case r = <-res:
+			case <-ctx.Done():
+				yield(zero, ctx.Err())
+				return
+			}
+			if !yield(r.v, r.err) || r.err != nil {
+				return
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// Tee returns n sequences each returning the values of seq, which is read
+// once by a goroutine started by the first consumer. The sequences must be
+// consumed concurrently, each at most once: seq is read at the pace of the
+// slowest consumer, up to buffer values ahead. A consumer stopping early
+// leaves the others running, the goroutine stops once all have stopped or
+// when ctx is cancelled.
+func Tee[T any](ctx context.Context, seq iter.Seq2[T, error], n, buffer int) []iter.Seq2[T, error] {
+	outs := make([]chan result[T], n)
+	stops := make([]chan struct{}, n)
+	stopOnce := make([]sync.Once, n)
+	for i := range outs {
+		outs[i] = make(chan result[T], max(buffer, 0))
+		stops[i] = make(chan struct{})
+	}
+
+	// cancelled is set before the outputs are closed when ctx ends the
+	// sequence.
+	var cancelled error
+	var start sync.Once
+	read := func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+
+		stopped := make([]bool, n)
+		running := n
+		for v, err := range seq {
+			for i, out := range outs {
+				if stopped[i] {
+					continue
+				}
+				select {
+				case out <- result[T]{v, err}:
+				case <-stops[i]:
+					stopped[i] = true
+					running--
+				case <-ctx.Done():
+					cancelled = ctx.Err()
+					return
+				}
+			}
+			if running == 0 || err != nil {
+				return
+			}
+		}
+	}
+
+	seqs := make([]iter.Seq2[T, error], n)
+	for i := range seqs {
+		seqs[i] = func(yield func(T, error) bool) {
+			defer stopOnce[i].Do(func() { close(stops[i]) })
+			start.Do(func() { go read() })
+
+			for r := range outs[i] {
+				if !yield(r.v, r.err) || r.err != nil {
+					return
+				}
+			}
+			if cancelled != nil {
+				var zero T
+				yield(zero, cancelled)
+			}
+		}
+	}
+	return seqs
+}
@@ -0,0 +1,157 @@
+// Package iterx composes sequences of values and errors, such as the pages
+// streamed from the database, into pipelines.
+//
+// A sequence yields either a value or an error. The first error ends the
+// sequence: the combinators forward it and stop, so that a consumer only
+// checks the errors once at the end of the pipeline:
+//
+//	pages := iterx.Take(iterx.Filter(db.StreamPages(ctx, 0), isArticle), 10)
+//	for p, err := range pages {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The combinators running goroutines take a context, they stop their
+// goroutines when the consumer stops or when the context is cancelled.
+package iterx
+
+import (
+	"context"
+	"iter"
+)
+
+// Map returns the values of seq transformed by fn. An error of fn ends the
+// sequence.
+func Map[T, U any](seq iter.Seq2[T, error], fn func(T) (U, error)) iter.Seq2[U, error] {
+	return func(yield func(U, error) bool) {
+		var zero U
+		for v, err := range seq {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			u, err := fn(v)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(u, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Filter returns the values of seq for which keep returns true.
+func Filter[T any](seq iter.Seq2[T, error], keep func(T) bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if err != nil {
+				yield(v, err)
+				return
+			}
+			if keep(v) && !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Batch groups the values of seq into slices of n values, the last slice
+// may be shorter. Each slice is newly allocated. The values read before an
+// error are dropped.
+func Batch[T any](seq iter.Seq2[T, error], n int) iter.Seq2[[]T, error] {
+	n = max(n, 1)
+	return func(yield func([]T, error) bool) {
+		batch := make([]T, 0, n)
+		for v, err := range seq {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			batch = append(batch, v)
+			if len(batch) < n {
+				continue
+			}
+			if !yield(batch, nil) {
+				return
+			}
+			batch = make([]T, 0, n)
+		}
+		if len(batch) > 0 {
+			yield(batch, nil)
+		}
+	}
+}
+
+// Take returns the first n values of seq. It stops reading seq once n values
+// are returned.
+func Take[T any](seq iter.Seq2[T, error], n int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if n <= 0 {
+			return
+		}
+		count := 0
+		for v, err := range seq {
+			if err != nil {
+				yield(v, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+			if count++; count == n {
+				return
+			}
+		}
+	}
+}
+
+// Skip returns the values of seq after the first n.
+func Skip[T any](seq iter.Seq2[T, error], n int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		count := 0
+		for v, err := range seq {
+			if err != nil {
+				yield(v, err)
+				return
+			}
+			if count < n {
+				count++
+				continue
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Collect returns the values of seq or its error.
+func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var values []T
+	for v, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// WithContext ends seq with the error of ctx once ctx is cancelled.
+func WithContext[T any](ctx context.Context, seq iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if err == nil && ctx.Err() != nil {
+				var zero T
+				v, err = zero, ctx.Err()
+			}
+			if !yield(v, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package iterx
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test error")
+
+// numbers returns the numbers in [0, n), then err if not nil. It counts the
+// numbers read in read.
+func numbers(n int, err error, read *int) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		for i := range n {
+			if read != nil {
+				*read++
+			}
+			if !yield(i, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(0, err)
+		}
+	}
+}
+
+func isEven(v int) bool { return v%2 == 0 }
+
+func TestCombinators(t *testing.T) {
+	double := func(v int) (int, error) { return 2 * v, nil }
+	failAt := func(at int) func(int) (int, error) {
+		return func(v int) (int, error) {
+			if v == at {
+				return 0, errTest
+			}
+			return v, nil
+		}
+	}
+
+	tests := []struct {
+		name    string
+		seq     iter.Seq2[int, error]
+		want    []int
+		wantErr error
+	}{
+		{name: "map", seq: Map(numbers(4, nil, nil), double), want: []int{0, 2, 4, 6}},
+		{name: "map/fn error", seq: Map(numbers(4, nil, nil), failAt(2)), wantErr: errTest},
+		{name: "map/seq error", seq: Map(numbers(4, errTest, nil), double), wantErr: errTest},
+		{name: "filter", seq: Filter(numbers(7, nil, nil), isEven), want: []int{0, 2, 4, 6}},
+		{name: "filter/error", seq: Filter(numbers(7, errTest, nil), isEven), wantErr: errTest},
+		{name: "take", seq: Take(numbers(10, errTest, nil), 3), want: []int{0, 1, 2}},
+		{name: "take/zero", seq: Take(numbers(10, nil, nil), 0), want: nil},
+		{name: "take/more", seq: Take(numbers(2, nil, nil), 3), want: []int{0, 1}},
+		{name: "skip", seq: Skip(numbers(5, nil, nil), 3), want: []int{3, 4}},
+		{name: "skip/error", seq: Skip(numbers(2, errTest, nil), 3), wantErr: errTest},
+		{name: "compose", seq: Take(Skip(Filter(numbers(20, errTest, nil), isEven), 2), 3), want: []int{4, 6, 8}},
+	}
+	for _, tt := range tests {
+		got, err := Collect(tt.seq)
+		if !errors.Is(err, tt.wantErr) || !slices.Equal(got, tt.want) {
+			t.Fatalf("%v: unexpected result: %v %v", tt.name, got, err)
+		}
+	}
+
+	// Take stops reading the sequence.
+	var read int
+	if _, err := Collect(Take(numbers(10, nil, &read), 3)); err != nil || read != 3 {
+		t.Fatalf("take: unexpected read: %d %v", read, err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	var got [][]int
+	for batch, err := range Batch(numbers(7, nil, nil), 3) {
+		if err != nil {
+			t.Fatalf("batch: %v", err)
+		}
+		got = append(got, batch)
+	}
+	want := [][]int{{0, 1, 2}, {3, 4, 5}, {6}}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Fatalf("unexpected batches: %v", got)
+	}
+
+	if _, err := Collect(Batch(numbers(7, errTest, nil), 3)); !errors.Is(err, errTest) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got []int
+	for v, err := range WithContext(ctx, numbers(10, nil, nil)) {
+		if err != nil {
+			if !errors.Is(err, context.Canceled) || !slices.Equal(got, []int{0, 1, 2}) {
+				t.Fatalf("unexpected result: %v %v", got, err)
+			}
+			return
+		}
+		if got = append(got, v); len(got) == 3 {
+			cancel()
+		}
+	}
+	t.Fatalf("expects an error")
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	got, err := Collect(Merge(ctx, numbers(100, nil, nil), Map(numbers(100, nil, nil), func(v int) (int, error) {
+		return v + 100, nil
+	})))
+	slices.Sort(got)
+	if err != nil || !slices.Equal(got, slices.Collect(func(yield func(int) bool) {
+		for i := range 200 {
+			yield(i)
+		}
+	})) {
+		t.Fatalf("unexpected result: %v %v", got, err)
+	}
+
+	if _, err := Collect(Merge(ctx, numbers(100, nil, nil), numbers(3, errTest, nil))); !errors.Is(err, errTest) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Stopping early stops the goroutines.
+	for range Merge(ctx, numbers(1000, nil, nil), numbers(1000, nil, nil)) {
+		break
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Collect(Merge(cctx, numbers(1000, nil, nil))); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParallelMap(t *testing.T) {
+	ctx := context.Background()
+	format := func(_ context.Context, v int) (string, error) {
+		// Later values finish first.
+		time.Sleep(time.Duration(10-v%10) * 100 * time.Microsecond)
+		return strconv.Itoa(v), nil
+	}
+
+	for _, workers := range []int{0, 1, 4} {
+		got, err := Collect(ParallelMap(ctx, numbers(50, nil, nil), workers, format))
+		if err != nil || len(got) != 50 {
+			t.Fatalf("workers=%d: unexpected result: %v %v", workers, got, err)
+		}
+		for i, s := range got {
+			if s != strconv.Itoa(i) {
+				t.Fatalf("workers=%d: unexpected order: %v", workers, got)
+			}
+		}
+	}
+
+	if _, err := Collect(ParallelMap(ctx, numbers(50, errTest, nil), 4, format)); !errors.Is(err, errTest) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fail := func(_ context.Context, v int) (string, error) {
+		if v == 7 {
+			return "", errTest
+		}
+		return strconv.Itoa(v), nil
+	}
+	got, err := Collect(ParallelMap(ctx, numbers(50, nil, nil), 4, fail))
+	if !errors.Is(err, errTest) || got != nil {
+		t.Fatalf("unexpected result: %v %v", got, err)
+	}
+
+	// Stopping early stops the goroutines, the values are read ahead by at
+	// most 2×workers.
+	var read int
+	for range ParallelMap(ctx, numbers(1000, nil, &read), 2, format) {
+		break
+	}
+	if read > 6 {
+		t.Fatalf("unexpected read ahead: %d", read)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Collect(ParallelMap(cctx, numbers(1000, nil, nil), 2, format)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTee(t *testing.T) {
+	ctx := context.Background()
+
+	collect := func(seqs []iter.Seq2[int, error]) ([][]int, []error) {
+		got := make([][]int, len(seqs))
+		errs := make([]error, len(seqs))
+		var wg sync.WaitGroup
+		for i, seq := range seqs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got[i], errs[i] = Collect(seq)
+			}()
+		}
+		wg.Wait()
+		return got, errs
+	}
+
+	got, errs := collect(Tee(ctx, numbers(100, nil, nil), 3, 4))
+	for i := range got {
+		if errs[i] != nil || len(got[i]) != 100 || !slices.Equal(got[i], got[0]) {
+			t.Fatalf("%d: unexpected result: %v %v", i, got[i], errs[i])
+		}
+	}
+
+	_, errs = collect(Tee(ctx, numbers(100, errTest, nil), 2, 0))
+	for i, err := range errs {
+		if !errors.Is(err, errTest) {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+	}
+
+	// A consumer stopping early leaves the others running.
+	seqs := Tee(ctx, numbers(100, nil, nil), 2, 0)
+	seqs[0] = Take(seqs[0], 1)
+	got, errs = collect(seqs)
+	if errs[0] != nil || errs[1] != nil || len(got[0]) != 1 || len(got[1]) != 100 {
+		t.Fatalf("unexpected result: %d %d %v", len(got[0]), len(got[1]), errs)
+	}
+
+	// A cancelled context ends all the sequences.
+	cctx, cancel := context.WithCancel(ctx)
+	seqs = Tee(cctx, numbers(1<<30, nil, nil), 2, 0)
+	first := seqs[0]
+	seqs[0] = func(yield func(int, error) bool) {
+		defer cancel()
+		for v, err := range Take(first, 5) {
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+	_, errs = collect(seqs)
+	if !errors.Is(errs[1], context.Canceled) {
+		t.Fatalf("unexpected error: %v", errs[1])
+	}
+}
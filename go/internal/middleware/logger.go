@@ -25,26 +25,38 @@ func Logger(logger *slog.Logger, next http.Handler) http.Handler {
 		start := time.Now()
 		wlog := newResponseLogger(w)
 
+		// Log aborted requests too, the panic is forwarded to the server.
+		aborted := true
+		defer func() { logRequest(logger, r, wlog, start, aborted) }()
+
 		next.ServeHTTP(wlog, r)
-		if wlog.status == 0 {
-			wlog.status = http.StatusOK
-		}
-
-		var stats runtime.MemStats
-		runtime.ReadMemStats(&stats)
-
-		attrs := []slog.Attr{
-			slog.String("method", r.Method),
-			slog.String("url", r.URL.String()),
-			slog.Int("status", wlog.status),
-			slog.String("size", formatByteCount(uint64(wlog.size))),
-			slog.String("heap", formatByteCount(stats.HeapAlloc)),
-			slog.Duration("duration", time.Since(start).Round(time.Millisecond)),
-		}
-		logger.LogAttrs(context.Background(), slog.LevelInfo, "incoming request", attrs...)
+		aborted = false
 	})
 }
 
+// logRequest logs the request once the handler returns or panics.
+func logRequest(logger *slog.Logger, r *http.Request, wlog *responseLogger, start time.Time, aborted bool) {
+	if wlog.status == 0 {
+		wlog.status = http.StatusOK
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.Int("status", wlog.status),
+		slog.String("size", formatByteCount(uint64(wlog.size))),
+		slog.String("heap", formatByteCount(stats.HeapAlloc)),
+		slog.Duration("duration", time.Since(start).Round(time.Millisecond)),
+	}
+	if aborted {
+		attrs = append(attrs, slog.Bool("aborted", true))
+	}
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "incoming request", attrs...)
+}
+
 // responseLogger is wrapper of http.ResponseWriter that keeps track of its HTTP
 // status code and body size
 type responseLogger struct {
@@ -104,6 +116,18 @@ func (l *responseLogger) Flush() {
 	}
 }
 
+// FlushError empty the response writer and returns the error, it is used by
+// [http.ResponseController].
+func (l *responseLogger) FlushError() error {
+	return http.NewResponseController(l.w).Flush()
+}
+
+// Unwrap returns the underlying response writer, it allows
+// [http.ResponseController] to reach features not exposed by the logger.
+func (l *responseLogger) Unwrap() http.ResponseWriter {
+	return l.w
+}
+
 func formatByteCount(b uint64) string {
 	const unit = 1000
 	if b < unit {
@@ -3,9 +3,15 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -22,9 +28,79 @@ func main() {
 		Logger: slog.Default(),
 	}
 	flag.StringVar(&params.Bind, "bind", "127.0.0.1:8080", "adress of the HTTP server")
-	flag.StringVar(&params.DB, "db", "stream.db", "path to the SQLite database")
+	flag.DurationVar(&params.Watch, "watch", 0, "reload replaced database files every interval, 0 disables the watcher, SIGHUP reloads them on demand")
+	flag.StringVar(&params.AdminBind, "admin-bind", "", "address of the admin HTTP server, e.g. 127.0.0.1:8081, empty disables it")
+	flag.StringVar(&params.GRPCBind, "grpc-bind", "", "adress of the gRPC server, empty disables it")
+	flag.StringVar(&params.DB, "db", "stream.db", "path to the SQLite database served at the root routes, empty disables them")
+	flag.Func("collection", "serve the database `name=path` under /name/, can be repeated", func(v string) error {
+		name, path, ok := strings.Cut(v, "=")
+		if !ok {
+			return fmt.Errorf("expects name=path")
+		}
+		if params.Collections == nil {
+			params.Collections = make(map[string]string)
+		}
+		params.Collections[name] = path
+		return nil
+	})
+	flag.IntVar(&params.Shards, "shards", 0, "connections reading /pages.stream in parallel, 0 or 1 reads with a single cursor")
+	flag.IntVar(&params.DefaultLimit, "default-limit", 0, "number of pages returned without limit parameter, 0 returns everything up to max-limit")
+	flag.IntVar(&params.MaxLimit, "max-limit", 0, "maximum limit parameter, 0 disables the maximum")
+	flag.IntVar(&params.Flush.Pages, "flush-pages", 0, "flush streamed responses every N pages")
+	flag.IntVar(&params.Flush.Bytes, "flush-bytes", 0, "flush streamed responses every N bytes")
+	flag.DurationVar(&params.Flush.Interval, "flush-interval", 0, "flush streamed responses at least every interval")
+	flag.DurationVar(&params.SlowClient.WriteTimeout, "write-timeout", 30*time.Second, "abort streamed responses when a write blocks longer, 0 disables the deadline")
+	flag.IntVar(&params.SlowClient.MinRate, "min-rate", 0, "abort streamed responses drained below N bytes/s, 0 disables the check")
+	flag.DurationVar(&params.SlowClient.Grace, "slow-grace", 5*time.Second, "writing time before min-rate is enforced")
+	flag.Func("request-memory", "approximate `size` of the memory a request can hold, such as 512MiB, 0 disables the budget", func(v string) error {
+		n, err := parseBytes(v)
+		params.RequestMemory = n
+		return err
+	})
+	flag.Func("memory-limit", "soft memory `size` limit of the process, such as 2GiB, overrides GOMEMLIMIT", func(v string) error {
+		n, err := parseBytes(v)
+		if err != nil {
+			return err
+		}
+		debug.SetMemoryLimit(n)
+		return nil
+	})
+	flag.DurationVar(&params.QueryTimeout, "query-timeout", 0, "timeout of the read-only SQL queries of /query, 0 disables the endpoint")
+	flag.Func("redact", "replace the matches of the `regexp` in the titles and texts with [redacted], can be repeated", func(v string) error {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return err
+		}
+		params.Transforms = append(params.Transforms, Redact(re))
+		return nil
+	})
+	normalize := flag.Bool("normalize-titles", false, "normalize the titles like MediaWiki")
+	truncate := flag.Int("truncate-text", 0, "truncate the texts longer than N bytes, 0 disables the truncation")
+	var keep []string
+	flag.Func("namespace", "only return the pages of the `name` space, main for the articles, can be repeated", func(v string) error {
+		if !validNamespace(v) {
+			return fmt.Errorf("unknown namespace %q", v)
+		}
+		keep = append(keep, v)
+		return nil
+	})
 	flag.Parse()
 
+	// The titles are normalized before the namespaces are read, the texts
+	// are redacted before they are truncated.
+	if *normalize {
+		params.Transforms = append(params.Transforms, NormalizeTitle)
+	}
+	if *truncate < 0 {
+		fatal("negative truncate-text")
+	}
+	if *truncate > 0 {
+		params.Transforms = append(params.Transforms, TruncateText(*truncate))
+	}
+	if len(keep) > 0 {
+		params.Filters = append(params.Filters, KeepNamespaces(keep...))
+	}
+
 	stream, err := NewStream(params)
 	if err != nil {
 		fatal("fail to instanciate Stream", "err", err)
@@ -36,8 +112,16 @@ func main() {
 	}
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt)
-	<-sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		err := stream.Reload()
+		if err != nil {
+			slog.Error("fail to reload databases", "err", err)
+		}
+	}
 
 	err = stream.Close()
 	if err != nil {
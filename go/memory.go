@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// gzipWriterMemory approximates the memory held by a [gzip.Writer] at the
+// default level, mostly its window and hash tables.
+const gzipWriterMemory = 800 << 10
+
+// pageMemory approximates the memory held by p.
+func pageMemory(p *Page) int64 {
+	return int64(unsafe.Sizeof(*p)) + int64(len(p.Title)+len(p.Text))
+}
+
+// pagesMemory approximates the memory held by pages.
+func pagesMemory(pages []Page) int64 {
+	var n int64
+	for i := range pages {
+		n += pageMemory(&pages[i])
+	}
+	return n
+}
+
+// grow adds n bytes to the memory held by the request and fails with a 507
+// once it exceeds the budget of the request, info may be nil. The bytes are
+// held even when it fails.
+func (info *streamInfo) grow(n int64) error {
+	if info == nil {
+		return nil
+	}
+	held := info.memory.Add(n)
+	for peak := info.peakMemory.Load(); held > peak; peak = info.peakMemory.Load() {
+		if info.peakMemory.CompareAndSwap(peak, held) {
+			break
+		}
+	}
+	if info.budget > 0 && held > info.budget {
+		return &apiError{
+			status:  http.StatusInsufficientStorage,
+			code:    codeMemoryBudget,
+			message: fmt.Sprintf("request exceeds its memory budget of %d bytes, use a limit or /pages.stream", info.budget),
+		}
+	}
+	return nil
+}
+
+// shrink removes n bytes from the memory held by the request, info may be
+// nil.
+func (info *streamInfo) shrink(n int64) {
+	if info != nil {
+		info.memory.Add(-n)
+	}
+}
+
+// parseBytes parses a number of bytes with an optional B, KiB, MiB or GiB
+// suffix. The KB, MB and GB suffixes are read as their binary counterpart.
+func parseBytes(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		shift  uint
+	}{
+		{"KiB", 10}, {"MiB", 20}, {"GiB", 30},
+		{"KB", 10}, {"MB", 20}, {"GB", 30},
+		{"B", 0},
+	}
+
+	num, shift := strings.TrimSpace(s), uint(0)
+	for _, u := range units {
+		if tmp, ok := strings.CutSuffix(num, u.suffix); ok {
+			num, shift = strings.TrimSpace(tmp), u.shift
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n << shift, nil
+}
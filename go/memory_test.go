@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	jsonv2 "github.com/go-json-experiment/json"
+)
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "1024", want: 1024},
+		{in: "12B", want: 12},
+		{in: "4KiB", want: 4 << 10},
+		{in: "512MiB", want: 512 << 20},
+		{in: "512MB", want: 512 << 20},
+		{in: "2 GiB", want: 2 << 30},
+		{in: "", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "1.5GiB", wantErr: true},
+		{in: "1TiB", wantErr: true},
+		{in: "9000000000GiB", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBytes(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Fatalf("%q: unexpected result: %d %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestRequestMemory(t *testing.T) {
+	s := newTestStream(t)
+	h := s.handler()
+
+	get := func(url string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
+		return resp
+	}
+	want := map[string][]byte{}
+	for _, name := range []string{"iter", "slice"} {
+		want[name] = get("/pages.list." + name).Body.Bytes()
+	}
+
+	// The budget holds a few pages of the test database.
+	s.requestMemory = 2000
+
+	// The buffering encoders switch to streaming and return the same pages.
+	for _, name := range []string{"iter", "slice"} {
+		resp := get("/pages.list." + name)
+		if resp.Code != 200 || !bytes.Equal(resp.Body.Bytes(), want[name]) {
+			t.Fatalf("%v: unexpected response: %d %s", name, resp.Code, resp.Body)
+		}
+	}
+
+	// The encoders listing the pages at once abort.
+	for _, name := range []string{"std", "exp"} {
+		resp := get("/pages.list." + name)
+		var r response
+		if err := jsonv2.Unmarshal(resp.Body.Bytes(), &r); err != nil || resp.Code != 507 || r.Code != codeMemoryBudget {
+			t.Fatalf("%v: unexpected response: %d %+v %v", name, resp.Code, r, err)
+		}
+	}
+
+	// A limit keeps the request under its budget.
+	if resp := get("/pages.list.std?limit=1"); resp.Code != 200 {
+		t.Fatalf("unexpected status: %d", resp.Code)
+	}
+}
+
+func TestStreamInfoMemory(t *testing.T) {
+	var reg streamRegistry
+	info := reg.add("/pages.list", "client", 100, func() {})
+
+	if err := info.grow(60); err != nil {
+		t.Fatalf("grow: %v", err)
+	}
+	if err := info.grow(60); err == nil {
+		t.Fatalf("grow: expects an error over the budget")
+	}
+	info.shrink(120)
+	if err := info.grow(10); err != nil {
+		t.Fatalf("grow: %v", err)
+	}
+	if got, peak := info.memory.Load(), info.peakMemory.Load(); got != 10 || peak != 120 {
+		t.Fatalf("unexpected memory: %d peak=%d", got, peak)
+	}
+
+	// The memory of a request without registry is not tracked.
+	info = streamFromContext(context.Background())
+	if err := info.grow(1 << 40); err != nil {
+		t.Fatalf("grow: %v", err)
+	}
+}
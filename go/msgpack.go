@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// msgpackEncoder streams pages as a sequence of MessagePack maps. A
+// MessagePack array needs its length upfront, the maps are written one after
+// the other like the NDJSON lines.
+type msgpackEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// Begin implements [pageEncoder].
+func (e *msgpackEncoder) Begin() error { return nil }
+
+// Encode implements [pageEncoder].
+func (e *msgpackEncoder) Encode(p *Page) error {
+	if err := checkUTF8(p); err != nil {
+		return err
+	}
+	e.buf = appendMsgPackPage(e.buf[:0], p)
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// End implements [pageEncoder].
+func (e *msgpackEncoder) End() error { return nil }
+
+// appendMsgPackPage appends p as a map with the keys of the JSON encoding.
+// UpdatedAt is a timestamp extension.
+func appendMsgPackPage(b []byte, p *Page) []byte {
+	b = append(b, 0x84) // fixmap of 4 entries
+	b = appendMsgPackString(b, "ID")
+	b = appendMsgPackInt(b, p.ID)
+	b = appendMsgPackString(b, "UpdatedAt")
+	b = appendMsgPackTime(b, p.UpdatedAt)
+	b = appendMsgPackString(b, "Title")
+	b = appendMsgPackString(b, p.Title)
+	b = appendMsgPackString(b, "Text")
+	b = appendMsgPackString(b, p.Text)
+	return b
+}
+
+func appendMsgPackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v <= 0x7f, v < 0 && v >= -32:
+		return append(b, byte(v))
+	case v >= 0 && v <= 0xff:
+		return append(b, 0xcc, byte(v))
+	case v >= 0 && v <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v >= 0 && v <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	case v >= 0:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), uint64(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+func appendMsgPackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= 0xff:
+		b = append(b, 0xd9, byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+// appendMsgPackTime appends t with the smallest timestamp extension that holds
+// it.
+func appendMsgPackTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	switch {
+	case nsec == 0 && sec >= 0 && sec <= 0xffffffff:
+		b = append(b, 0xd6, 0xff) // fixext 4, type -1
+		return binary.BigEndian.AppendUint32(b, uint32(sec))
+	case sec >= 0 && sec < 1<<34:
+		b = append(b, 0xd7, 0xff) // fixext 8, type -1
+		return binary.BigEndian.AppendUint64(b, uint64(nsec)<<34|uint64(sec))
+	default:
+		b = append(b, 0xc7, 12, 0xff) // ext 8 of 12 bytes, type -1
+		b = binary.BigEndian.AppendUint32(b, uint32(nsec))
+		return binary.BigEndian.AppendUint64(b, uint64(sec))
+	}
+}
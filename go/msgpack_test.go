@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func decodeMsgPack(body []byte) ([]map[string]any, error) {
+	d := msgpack.NewDecoder(bytes.NewReader(body))
+	d.DisallowUnknownFields(true)
+
+	pages := []map[string]any{}
+	for {
+		var p Page
+		err := d.Decode(&p)
+		if errors.Is(err, io.EOF) {
+			return pages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, map[string]any{
+			"ID":        float64(p.ID),
+			"UpdatedAt": p.UpdatedAt.UTC().Format(time.RFC3339Nano),
+			"Title":     p.Title,
+			"Text":      p.Text,
+		})
+	}
+}
+
+func TestAppendMsgPack(t *testing.T) {
+	for _, v := range []int64{0, 1, 0x7f, 0x80, 0xff, 0x100, 0xffff, 0x10000, 0xffffffff, 0x100000000, math.MaxInt64, -1, -32, -33, math.MinInt64} {
+		var got int64
+		if err := msgpack.Unmarshal(appendMsgPackInt(nil, v), &got); err != nil || got != v {
+			t.Fatalf("int %d: got=%d err=%v", v, got, err)
+		}
+	}
+
+	for _, n := range []int{0, 31, 32, 0xff, 0x100, 0xffff, 0x10000} {
+		v := string(bytes.Repeat([]byte("a"), n))
+		var got string
+		if err := msgpack.Unmarshal(appendMsgPackString(nil, v), &got); err != nil || got != v {
+			t.Fatalf("string of %d bytes: got %d bytes, err=%v", n, len(got), err)
+		}
+	}
+
+	for _, v := range []time.Time{
+		time.Unix(0, 0),
+		time.Unix(1700000000, 0),
+		time.Unix(1700000000, 123456789),
+		time.Unix(1<<34, 0),
+		time.Unix(-1, 5),
+	} {
+		var got time.Time
+		if err := msgpack.Unmarshal(appendMsgPackTime(nil, v), &got); err != nil || !got.Equal(v) {
+			t.Fatalf("time %v: got=%v err=%v", v, got, err)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package pagespb holds the protobuf messages and the gRPC service of the
+// pages.
+package pagespb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pages.proto
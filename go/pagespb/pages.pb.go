@@ -0,0 +1,293 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: pages.proto
+
+package pagespb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Page stores information on a Wiki page.
+type Page struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Title         string                 `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Text          string                 `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Page) Reset() {
+	*x = Page{}
+	mi := &file_pages_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Page) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Page) ProtoMessage() {}
+
+func (x *Page) ProtoReflect() protoreflect.Message {
+	mi := &file_pages_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Page.ProtoReflect.Descriptor instead.
+func (*Page) Descriptor() ([]byte, []int) {
+	return file_pages_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Page) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Page) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Page) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Page) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+// StreamRequest selects the pages to stream.
+type StreamRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Collection is the name of the collection, empty for the default one.
+	Collection string `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	// Limit is the maximum number of pages, 0 uses the server default.
+	Limit int64 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	// After streams the pages with an ID greater than after.
+	After int64 `protobuf:"varint,3,opt,name=after,proto3" json:"after,omitempty"`
+	// BatchSize is the maximum number of pages of a batch, 0 uses the server
+	// default. Ignored by StreamPages.
+	BatchSize     int32 `protobuf:"varint,4,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamRequest) Reset() {
+	*x = StreamRequest{}
+	mi := &file_pages_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamRequest) ProtoMessage() {}
+
+func (x *StreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pages_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamRequest.ProtoReflect.Descriptor instead.
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return file_pages_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StreamRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *StreamRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *StreamRequest) GetAfter() int64 {
+	if x != nil {
+		return x.After
+	}
+	return 0
+}
+
+func (x *StreamRequest) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+// PageBatch is a batch of pages.
+type PageBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pages         []*Page                `protobuf:"bytes,1,rep,name=pages,proto3" json:"pages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PageBatch) Reset() {
+	*x = PageBatch{}
+	mi := &file_pages_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PageBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PageBatch) ProtoMessage() {}
+
+func (x *PageBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_pages_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PageBatch.ProtoReflect.Descriptor instead.
+func (*PageBatch) Descriptor() ([]byte, []int) {
+	return file_pages_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PageBatch) GetPages() []*Page {
+	if x != nil {
+		return x.Pages
+	}
+	return nil
+}
+
+var File_pages_proto protoreflect.FileDescriptor
+
+const file_pages_proto_rawDesc = "" +
+	"\n" +
+	"\vpages.proto\x12\tstream.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"{\n" +
+	"\x04Page\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x129\n" +
+	"\n" +
+	"updated_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\"z\n" +
+	"\rStreamRequest\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x01 \x01(\tR\n" +
+	"collection\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x03R\x05limit\x12\x14\n" +
+	"\x05after\x18\x03 \x01(\x03R\x05after\x12\x1d\n" +
+	"\n" +
+	"batch_size\x18\x04 \x01(\x05R\tbatchSize\"2\n" +
+	"\tPageBatch\x12%\n" +
+	"\x05pages\x18\x01 \x03(\v2\x0f.stream.v1.PageR\x05pages2\x8a\x01\n" +
+	"\x05Pages\x12:\n" +
+	"\vStreamPages\x12\x18.stream.v1.StreamRequest\x1a\x0f.stream.v1.Page0\x01\x12E\n" +
+	"\x11StreamPageBatches\x12\x18.stream.v1.StreamRequest\x1a\x14.stream.v1.PageBatch0\x01B#Z!github.com/y1w5/stream/go/pagespbb\x06proto3"
+
+var (
+	file_pages_proto_rawDescOnce sync.Once
+	file_pages_proto_rawDescData []byte
+)
+
+func file_pages_proto_rawDescGZIP() []byte {
+	file_pages_proto_rawDescOnce.Do(func() {
+		file_pages_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pages_proto_rawDesc), len(file_pages_proto_rawDesc)))
+	})
+	return file_pages_proto_rawDescData
+}
+
+var file_pages_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_pages_proto_goTypes = []any{
+	(*Page)(nil),                  // 0: stream.v1.Page
+	(*StreamRequest)(nil),         // 1: stream.v1.StreamRequest
+	(*PageBatch)(nil),             // 2: stream.v1.PageBatch
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+}
+var file_pages_proto_depIdxs = []int32{
+	3, // 0: stream.v1.Page.updated_at:type_name -> google.protobuf.Timestamp
+	0, // 1: stream.v1.PageBatch.pages:type_name -> stream.v1.Page
+	1, // 2: stream.v1.Pages.StreamPages:input_type -> stream.v1.StreamRequest
+	1, // 3: stream.v1.Pages.StreamPageBatches:input_type -> stream.v1.StreamRequest
+	0, // 4: stream.v1.Pages.StreamPages:output_type -> stream.v1.Page
+	2, // 5: stream.v1.Pages.StreamPageBatches:output_type -> stream.v1.PageBatch
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_pages_proto_init() }
+func file_pages_proto_init() {
+	if File_pages_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pages_proto_rawDesc), len(file_pages_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pages_proto_goTypes,
+		DependencyIndexes: file_pages_proto_depIdxs,
+		MessageInfos:      file_pages_proto_msgTypes,
+	}.Build()
+	File_pages_proto = out.File
+	file_pages_proto_goTypes = nil
+	file_pages_proto_depIdxs = nil
+}
@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: pages.proto
+
+package pagespb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Pages_StreamPages_FullMethodName       = "/stream.v1.Pages/StreamPages"
+	Pages_StreamPageBatches_FullMethodName = "/stream.v1.Pages/StreamPageBatches"
+)
+
+// PagesClient is the client API for Pages service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Pages streams the pages of a collection.
+type PagesClient interface {
+	// StreamPages streams the pages, a message per page.
+	StreamPages(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Page], error)
+	// StreamPageBatches streams the pages in batches.
+	StreamPageBatches(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PageBatch], error)
+}
+
+type pagesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPagesClient(cc grpc.ClientConnInterface) PagesClient {
+	return &pagesClient{cc}
+}
+
+func (c *pagesClient) StreamPages(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Page], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pages_ServiceDesc.Streams[0], Pages_StreamPages_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamRequest, Page]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pages_StreamPagesClient = grpc.ServerStreamingClient[Page]
+
+func (c *pagesClient) StreamPageBatches(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PageBatch], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pages_ServiceDesc.Streams[1], Pages_StreamPageBatches_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamRequest, PageBatch]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pages_StreamPageBatchesClient = grpc.ServerStreamingClient[PageBatch]
+
+// PagesServer is the server API for Pages service.
+// All implementations must embed UnimplementedPagesServer
+// for forward compatibility.
+//
+// Pages streams the pages of a collection.
+type PagesServer interface {
+	// StreamPages streams the pages, a message per page.
+	StreamPages(*StreamRequest, grpc.ServerStreamingServer[Page]) error
+	// StreamPageBatches streams the pages in batches.
+	StreamPageBatches(*StreamRequest, grpc.ServerStreamingServer[PageBatch]) error
+	mustEmbedUnimplementedPagesServer()
+}
+
+// UnimplementedPagesServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPagesServer struct{}
+
+func (UnimplementedPagesServer) StreamPages(*StreamRequest, grpc.ServerStreamingServer[Page]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamPages not implemented")
+}
+func (UnimplementedPagesServer) StreamPageBatches(*StreamRequest, grpc.ServerStreamingServer[PageBatch]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamPageBatches not implemented")
+}
+func (UnimplementedPagesServer) mustEmbedUnimplementedPagesServer() {}
+func (UnimplementedPagesServer) testEmbeddedByValue()               {}
+
+// UnsafePagesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PagesServer will
+// result in compilation errors.
+type UnsafePagesServer interface {
+	mustEmbedUnimplementedPagesServer()
+}
+
+func RegisterPagesServer(s grpc.ServiceRegistrar, srv PagesServer) {
+	// If the following call pancis, it indicates UnimplementedPagesServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Pages_ServiceDesc, srv)
+}
+
+func _Pages_StreamPages_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PagesServer).StreamPages(m, &grpc.GenericServerStream[StreamRequest, Page]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pages_StreamPagesServer = grpc.ServerStreamingServer[Page]
+
+func _Pages_StreamPageBatches_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PagesServer).StreamPageBatches(m, &grpc.GenericServerStream[StreamRequest, PageBatch]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pages_StreamPageBatchesServer = grpc.ServerStreamingServer[PageBatch]
+
+// Pages_ServiceDesc is the grpc.ServiceDesc for Pages service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Pages_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "stream.v1.Pages",
+	HandlerType: (*PagesServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamPages",
+			Handler:       _Pages_StreamPages_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamPageBatches",
+			Handler:       _Pages_StreamPageBatches_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pages.proto",
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/y1w5/stream/go/internal/iterx"
+)
+
+// maxShards is the maximum number of shards of [DB.StreamPagesParallel].
+const maxShards = 16
+
+// shardChunkSize is the number of pages read per query by the shards of a
+// parallel read.
+const shardChunkSize = 4096
+
+// shardBatchSize is the number of pages sent at once by the shards of an
+// unordered parallel read.
+const shardBatchSize = 256
+
+var rangePagesQuery = `SELECT id, updated_at, title, text FROM pages WHERE id > ? AND id <= ? ORDER BY id`
+
+// chunkBoundQuery returns the ID of the last page of the chunk after an ID.
+var chunkBoundQuery = `SELECT id FROM pages WHERE id > ? ORDER BY id LIMIT 1 OFFSET ?`
+
+// shardResult is a batch of pages read by a shard.
+type shardResult struct {
+	pages []Page
+	err   error
+}
+
+// readAhead charges the pages read ahead by the shards to the memory of the
+// request, see [streamInfo.grow]. Once the request exceeds its budget, the
+// shards wait for the consumer to release pages before reading more, except
+// the shard reading the chunk the consumer waits for.
+type readAhead struct {
+	info *streamInfo
+	mu   sync.Mutex
+	cond *sync.Cond
+	held int64
+	// next is the chunk the consumer waits for in ordered mode.
+	next int64
+}
+
+func newReadAhead(info *streamInfo) *readAhead {
+	ra := &readAhead{info: info}
+	ra.cond = sync.NewCond(&ra.mu)
+	return ra
+}
+
+// wait blocks the shard reading chunk, -1 when unordered, until the pages
+// read ahead fit in the budget or ctx is done. It reports whether ctx is not
+// done.
+func (ra *readAhead) wait(ctx context.Context, chunk int64) bool {
+	if ra.info == nil || ra.info.budget <= 0 {
+		return ctx.Err() == nil
+	}
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	for ra.held > 0 && chunk != ra.next && ra.info.memory.Load() > ra.info.budget && ctx.Err() == nil {
+		ra.cond.Wait()
+	}
+	return ctx.Err() == nil
+}
+
+// hold charges n bytes read ahead to the request. The budget is enforced by
+// wait, the error of grow is ignored.
+func (ra *readAhead) hold(n int64) {
+	ra.mu.Lock()
+	ra.held += n
+	_ = ra.info.grow(n)
+	ra.mu.Unlock()
+}
+
+// release releases n bytes given to the consumer, which then waits for the
+// next chunk.
+func (ra *readAhead) release(n int64) {
+	ra.mu.Lock()
+	ra.held -= n
+	ra.info.shrink(n)
+	ra.next++
+	ra.cond.Broadcast()
+	ra.mu.Unlock()
+}
+
+// wake wakes up the waiting shards, it is called when the context is done.
+func (ra *readAhead) wake() {
+	ra.mu.Lock()
+	ra.cond.Broadcast()
+	ra.mu.Unlock()
+}
+
+// StreamPagesParallel streams the pages with an ID greater than after like
+// [DB.StreamPagesAfter], reading them with shards goroutines each on its own
+// connection.
+//
+// The pages are cut into chunks of [shardChunkSize] pages, see
+// [DB.chunkBounds]. When ordered, the chunks are read by the shards in turn
+// and the pages are yielded in ID order, each shard reads ahead at most two
+// chunks. Otherwise each shard reads a contiguous run of chunks and the pages
+// are yielded as they are read. A limit forces the ID order.
+//
+// The pages read ahead are charged to the memory of the request, the shards
+// stop reading ahead while it exceeds its budget.
+func (db *DB) StreamPagesParallel(ctx context.Context, after int64, limit, shards int, ordered bool) func(func(Page, error) bool) {
+	return func(yield func(Page, error) bool) {
+		var chunks int
+		if limit > 0 {
+			chunks = (limit + shardChunkSize - 1) / shardChunkSize
+		}
+		bounds, err := db.chunkBounds(ctx, after, chunks)
+		if err != nil {
+			yield(Page{}, err)
+			return
+		}
+		shards = max(shards, 1)
+
+		ctx, cancel := context.WithCancel(ctx)
+		ra := newReadAhead(streamFromContext(ctx))
+		stop := context.AfterFunc(ctx, ra.wake)
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+			stop()
+			// The pages read ahead and not consumed.
+			ra.info.shrink(ra.held)
+		}()
+		send := func(ch chan<- shardResult, r shardResult) bool {
+			select {
+			case ch <- r:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if ordered || limit > 0 {
+			var count int
+			for pages, err := range db.streamChunks(ctx, &wg, ra, send, bounds, shards) {
+				if err != nil {
+					yield(Page{}, err)
+					return
+				}
+				for _, p := range pages {
+					if !yield(p, nil) {
+						return
+					}
+					if count++; count == limit {
+						return
+					}
+				}
+				ra.release(pagesMemory(pages))
+			}
+			return
+		}
+
+		out := make(chan shardResult, 2*shards)
+		chunks = len(bounds) - 1
+		for i := range shards {
+			from, to := bounds[i*chunks/shards], bounds[(i+1)*chunks/shards]
+			if from == to {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// The shard waits for the budget between the batches only:
+				// the consumer waits for whole batches.
+				var n int
+				pages := iterx.Map(db.streamPages(ctx, rangePagesQuery, from, to), func(p Page) (Page, error) {
+					if n%shardBatchSize == 0 && !ra.wait(ctx, -1) {
+						return p, ctx.Err()
+					}
+					n++
+					ra.hold(pageMemory(&p))
+					return p, nil
+				})
+				for batch, err := range iterx.Batch(pages, shardBatchSize) {
+					if !send(out, shardResult{pages: batch, err: err}) || err != nil {
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+
+		for r := range out {
+			if r.err != nil {
+				yield(Page{}, r.err)
+				return
+			}
+			for _, p := range r.pages {
+				if !yield(p, nil) {
+					return
+				}
+			}
+			ra.release(pagesMemory(r.pages))
+		}
+		if err := ctx.Err(); err != nil {
+			yield(Page{}, err)
+		}
+	}
+}
+
+// chunkBounds returns the bounds of the chunks of [shardChunkSize] pages with
+// an ID greater than after, chunk i holding the IDs in (bounds[i],
+// bounds[i+1]]. It stops after limit chunks, 0 reading all the pages.
+//
+// The bounds follow the pages rather than the IDs: the gaps and the outliers
+// of the IDs neither create empty chunks nor grow the chunks.
+func (db *DB) chunkBounds(ctx context.Context, after int64, limit int) ([]int64, error) {
+	bounds := []int64{after}
+	for limit == 0 || len(bounds) <= limit {
+		var id int64
+		err := db.db.QueryRowContext(ctx, chunkBoundQuery, bounds[len(bounds)-1], shardChunkSize-1).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The last chunk holds the remaining pages.
+			return append(bounds, math.MaxInt64), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chunk bounds: %v", err)
+		}
+		bounds = append(bounds, id)
+	}
+	return bounds, nil
+}
+
+// streamChunks reads the chunks of bounds, see [DB.chunkBounds], chunk i being
+// read by the shard i%shards. The chunks are yielded in order, the caller
+// releases them from ra once consumed.
+func (db *DB) streamChunks(ctx context.Context, wg *sync.WaitGroup, ra *readAhead, send func(chan<- shardResult, shardResult) bool, bounds []int64, shards int) func(func([]Page, error) bool) {
+	return func(yield func([]Page, error) bool) {
+		chunks := int64(len(bounds) - 1)
+		results := make([]chan shardResult, shards)
+		for i := range results {
+			results[i] = make(chan shardResult, 2)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for c := int64(i); c < chunks; c += int64(shards) {
+					from, to := bounds[c], bounds[c+1]
+					var r shardResult
+					for p, err := range db.streamPages(ctx, rangePagesQuery, from, to) {
+						if err == nil && !ra.wait(ctx, c) {
+							err = ctx.Err()
+						}
+						if err != nil {
+							r = shardResult{err: err}
+							break
+						}
+						ra.hold(pageMemory(&p))
+						r.pages = append(r.pages, p)
+					}
+					if !send(results[i], r) || r.err != nil {
+						return
+					}
+				}
+			}()
+		}
+
+		for c := range chunks {
+			select {
+			case r := <-results[c%int64(shards)]:
+				if !yield(r.pages, r.err) || r.err != nil {
+					return
+				}
+			case <-ctx.Done():
+				yield(nil, ctx.Err())
+				return
+			}
+		}
+	}
+}
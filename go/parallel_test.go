@@ -0,0 +1,198 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/y1w5/stream/db/synth"
+)
+
+func TestStreamPagesParallel(t *testing.T) {
+	// The pages span several chunks, have gaps in their IDs and an outlier
+	// ID far after the others.
+	opts := synth.DefaultOptions()
+	opts.Pages = 5*shardChunkSize + 100
+	opts.MaxTextSize = 100
+	path := filepath.Join(t.TempDir(), "stream.db")
+	if err := synth.WriteDB(path, opts); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("new db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+	_, err = db.db.ExecContext(ctx, `DELETE FROM pages WHERE id % 7 = 0 OR id BETWEEN 5000 AND 9000`)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	_, err = db.db.ExecContext(ctx, `UPDATE pages SET id = 1 << 50 WHERE id = (SELECT max(id) FROM pages)`)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// The chunks hold shardChunkSize pages despite the gaps, the last one
+	// the remaining pages.
+	bounds, err := db.chunkBounds(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("chunk bounds: %v", err)
+	}
+	for i := range len(bounds) - 1 {
+		var count int
+		err := db.db.QueryRowContext(ctx, `SELECT count(*) FROM pages WHERE id > ? AND id <= ?`, bounds[i], bounds[i+1]).Scan(&count)
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		if i < len(bounds)-2 && count != shardChunkSize || count > shardChunkSize {
+			t.Fatalf("chunk %d: unexpected page count: %d", i, count)
+		}
+	}
+
+	ids := func(pages func(func(Page, error) bool)) []int64 {
+		t.Helper()
+		var ids []int64
+		for p, err := range pages {
+			if err != nil {
+				t.Fatalf("stream pages: %v", err)
+			}
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	for _, shards := range []int{1, 3, 8} {
+		for _, ordered := range []bool{true, false} {
+			for _, tt := range []struct{ after, limit int64 }{{0, 0}, {777, 0}, {0, 4100}, {100, 1}} {
+				t.Run(fmt.Sprintf("shards=%d/ordered=%v/after=%d/limit=%d", shards, ordered, tt.after, tt.limit), func(t *testing.T) {
+					want := ids(db.StreamPagesAfter(ctx, tt.after, int(tt.limit)))
+					got := ids(db.StreamPagesParallel(ctx, tt.after, int(tt.limit), shards, ordered))
+					if !ordered && tt.limit == 0 {
+						slices.Sort(got)
+					}
+					if !slices.Equal(got, want) {
+						t.Fatalf("unexpected pages: expects %d pages, got %d", len(want), len(got))
+					}
+				})
+			}
+		}
+	}
+
+	// Stopping early cancels the shards.
+	for _, ordered := range []bool{true, false} {
+		var count int
+		for _, err := range db.StreamPagesParallel(ctx, 0, 0, 4, ordered) {
+			if err != nil {
+				t.Fatalf("stream pages: %v", err)
+			}
+			if count++; count == 10 {
+				break
+			}
+		}
+	}
+
+	// The pages read ahead are charged to the request, bounded by its budget
+	// and released at the end.
+	var maxPage int64
+	for p, err := range db.StreamPagesAfter(ctx, 0, 0) {
+		if err != nil {
+			t.Fatalf("stream pages: %v", err)
+		}
+		maxPage = max(maxPage, pageMemory(&p))
+	}
+	want := ids(db.StreamPagesAfter(ctx, 0, 0))
+	for _, ordered := range []bool{true, false} {
+		for _, limit := range []int{0, 10} {
+			info := &streamInfo{budget: 64 << 10}
+			ctx := context.WithValue(ctx, streamInfoKey{}, info)
+			got := ids(db.StreamPagesParallel(ctx, 0, limit, 8, ordered))
+			if !ordered && limit == 0 {
+				slices.Sort(got)
+			}
+			if limit > 0 {
+				got = got[:min(len(got), limit)]
+			}
+			if !slices.Equal(got, want[:cmp.Or(limit, len(want))]) {
+				t.Fatalf("ordered=%v limit=%d: unexpected pages: %d", ordered, limit, len(got))
+			}
+			// The chunk the consumer waits for is read despite the budget, the
+			// other shards hold at most a page or a batch over it.
+			bound := info.budget + (shardChunkSize+8)*maxPage
+			if !ordered {
+				bound = info.budget + 8*shardBatchSize*maxPage
+			}
+			if peak := info.peakMemory.Load(); peak == 0 || peak > bound {
+				t.Fatalf("ordered=%v limit=%d: unexpected peak memory: %d", ordered, limit, peak)
+			}
+			if held := info.memory.Load(); held != 0 {
+				t.Fatalf("ordered=%v limit=%d: unexpected memory held: %d", ordered, limit, held)
+			}
+		}
+	}
+}
+
+func TestStreamPagesShardsOrder(t *testing.T) {
+	h := newTestStream(t).handler()
+
+	tests := []struct {
+		query     string
+		status    int
+		unordered bool
+	}{
+		{query: "shards=3&format=ndjson", status: http.StatusOK},
+		{query: "shards=3&format=ndjson&unordered=true", status: http.StatusOK, unordered: true},
+		{query: "shards=3&format=ndjson&unordered=true&limit=5", status: http.StatusOK},
+		{query: "shards=3&unordered=true", status: http.StatusBadRequest},
+		{query: "shards=3&format=ndjson&unordered=maybe", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest("GET", "/pages.stream?"+tt.query, nil))
+		if resp.Code != tt.status {
+			t.Fatalf("%v: unexpected status: expects=%d got=%d", tt.query, tt.status, resp.Code)
+		}
+		if got := resp.Header().Get(pageOrderHeader) == "unordered"; got != tt.unordered {
+			t.Fatalf("%v: unexpected order header: %q", tt.query, resp.Header().Get(pageOrderHeader))
+		}
+		if tt.status != http.StatusOK || tt.unordered {
+			continue
+		}
+		pages, err := decodePages(resp.Body.Bytes())
+		if err != nil {
+			t.Fatalf("%v: decode: %v", tt.query, err)
+		}
+		if !slices.IsSortedFunc(pages, func(a, b map[string]any) int { return cmp.Compare(a["ID"].(float64), b["ID"].(float64)) }) {
+			t.Fatalf("%v: pages not in ID order", tt.query)
+		}
+	}
+}
+
+func BenchmarkDBStreamPagesParallel(b *testing.B) {
+	db, err := NewDB(testDBPath(b))
+	if err != nil {
+		b.Fatalf("new DB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, shards := range []int{1, 2, 4, 8} {
+		for _, ordered := range []bool{true, false} {
+			b.Run(fmt.Sprintf("shards=%d/ordered=%v", shards, ordered), func(b *testing.B) {
+				for range b.N {
+					for p, err := range db.StreamPagesParallel(ctx, 0, 0, shards, ordered) {
+						if err != nil {
+							b.Fatalf("stream pages: %v", err)
+						}
+						_ = p
+					}
+				}
+			})
+		}
+	}
+}
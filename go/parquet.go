@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/y1w5/stream/db/export"
+)
+
+// parquetPages streams pages as a Parquet file.
+func (s *Stream) parquetPages(w http.ResponseWriter, r *http.Request) {
+	limit, err := s.parseLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	after, err := parseAfter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeParquet(w, r, svc.StreamPageSliceAfter(r.Context(), after, limit))
+}
+
+// writeParquet streams pages as a Parquet file. Each slice of pages is
+// written as a row group and flushed, the file is complete once the footer
+// is written after the last slice.
+func (s *Stream) writeParquet(w http.ResponseWriter, r *http.Request, slices func(func([]Page, error) bool)) {
+	fw := newFlushWriter(w, FlushPolicy{}, s.slowClient)
+	defer s.closeStream(r.Context(), fw)
+	fw.track(streamFromContext(r.Context()))
+
+	w.Header().Set("Content-Type", formatParquet.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="pages.parquet"`)
+
+	pw, err := export.NewParquetWriter(fw, export.DefaultParquetOptions())
+	if err != nil {
+		s.logger.Error("fail to create Parquet writer", "err", err)
+		writeError(w, err)
+		return
+	}
+
+	var buf []export.Page
+	info := streamFromContext(r.Context())
+	for pages, err := range slices {
+		if err != nil {
+			s.failStream(r.Context(), "fail to stream pages", err, formatParquet)
+			return
+		}
+
+		buf = exportPages(buf, pages)
+		err = pw.Write(buf)
+		if err != nil {
+			s.failStream(r.Context(), "fail to encode Parquet", err, formatParquet)
+			return
+		}
+		info.addPages(len(pages))
+
+		err = fw.Flush()
+		if err != nil {
+			s.failStream(r.Context(), "fail to flush response", err, formatParquet)
+			return
+		}
+	}
+
+	err = pw.Close()
+	if err != nil {
+		s.failStream(r.Context(), "fail to encode Parquet", err, formatParquet)
+		return
+	}
+}
+
+// exportPages converts pages into buf for the export package.
+func exportPages(buf []export.Page, pages []Page) []export.Page {
+	buf = buf[:0]
+	for _, p := range pages {
+		buf = append(buf, export.Page(p))
+	}
+	return buf
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// decodeParquet decodes a Parquet file into the generic objects returned by
+// [decodePages].
+func decodeParquet(body []byte) ([]map[string]any, error) {
+	r, err := file.NewParquetReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	fr, err := pqarrow.NewFileReader(r, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, err
+	}
+	tbl, err := fr.ReadTable(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tbl.Release()
+
+	pages := []map[string]any{}
+	tr := array.NewTableReader(tbl, 0)
+	defer tr.Release()
+	for tr.Next() {
+		rec := tr.RecordBatch()
+		ids := rec.Column(0).(*array.Int64)
+		updatedAt := rec.Column(1).(*array.Timestamp)
+		titles := rec.Column(2).(*array.String)
+		texts := rec.Column(3).(*array.String)
+		for i := range int(rec.NumRows()) {
+			pages = append(pages, map[string]any{
+				"ID":        float64(ids.Value(i)),
+				"UpdatedAt": updatedAt.Value(i).ToTime(arrow.Nanosecond).Format(time.RFC3339Nano),
+				"Title":     titles.Value(i),
+				"Text":      texts.Value(i),
+			})
+		}
+	}
+	return pages, tr.Err()
+}
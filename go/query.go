@@ -0,0 +1,357 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteRecursive is SQLITE_RECURSIVE, the action of a recursive common
+// table expression, missing from the driver constants.
+const sqliteRecursive = 33
+
+// maxQueryConns is the number of queries running at once per database, the
+// other queries wait for a connection until their timeout.
+const maxQueryConns = 4
+
+// maxQuerySize is the maximum size of a query.
+const maxQuerySize = 64 << 10
+
+// defaultQueryLength is the maximum length in bytes of a string or blob built
+// by a query when the requests have no memory budget.
+const defaultQueryLength = 64 << 20
+
+// queryLength returns the maximum length of a string or blob built by a query
+// under the memory budget of a request, 0 disabling it. It stops queries such
+// as SELECT zeroblob(2000000000) from exhausting the memory before their
+// timeout.
+func queryLength(budget int64) int {
+	if budget <= 0 {
+		return defaultQueryLength
+	}
+	return int(min(budget, math.MaxInt32))
+}
+
+// authorizeQuery allows the actions of a SELECT: ATTACH, PRAGMA and the
+// writes are denied.
+func authorizeQuery(action int, _, _, _ string) int {
+	switch action {
+	case sqlite3.SQLITE_SELECT, sqlite3.SQLITE_READ, sqlite3.SQLITE_FUNCTION, sqliteRecursive:
+		return sqlite3.SQLITE_OK
+	}
+	return sqlite3.SQLITE_DENY
+}
+
+// openQueryDB opens the read-only connections of path: the file is opened
+// with mode=ro, the query_only pragma is set, the authorizer only allows
+// reading and the strings and blobs are limited to length bytes.
+func openQueryDB(path string, length int) *sql.DB {
+	drv := &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			conn.RegisterAuthorizer(authorizeQuery)
+			conn.SetLimit(sqlite3.SQLITE_LIMIT_LENGTH, length)
+			return nil
+		},
+	}
+	db := sql.OpenDB(queryConnector{dsn: queryURI(path), driver: drv})
+	db.SetMaxOpenConns(maxQueryConns)
+	return db
+}
+
+// queryConnector opens the connections of a driver with its own hook, a
+// registered driver would share it between the databases.
+type queryConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+// Connect implements [driver.Connector].
+func (c queryConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver implements [driver.Connector].
+func (c queryConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// queryURI returns the URI opening path read-only with the query_only
+// pragma.
+func queryURI(path string) string {
+	return "file:" + uriEscaper.Replace(path) + "?mode=ro&_query_only=true"
+}
+
+// queryMethods are the methods of /query, a POST holds the query in its
+// body. A HEAD would run the query for nothing.
+var queryMethods = []string{http.MethodGet, http.MethodPost}
+
+var uriEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// Query runs a read-only query, see [openQueryDB]. An error of the query is
+// returned as is.
+func (db *DB) Query(ctx context.Context, query string) (*sql.Rows, error) {
+	conns, err := db.queryDB()
+	if err != nil {
+		return nil, err
+	}
+	return conns.QueryContext(ctx, query)
+}
+
+// queryDB returns the read-only connections of the database, opened on first
+// use.
+func (db *DB) queryDB() (*sql.DB, error) {
+	db.queryOnce.Do(func() {
+		db.query = openQueryDB(db.path, cmp.Or(db.queryLength, defaultQueryLength))
+	})
+	return db.query, db.queryErr
+}
+
+// queryColumn is a column of a query result.
+type queryColumn struct {
+	name string
+	// text is set for the columns with a text affinity, their byte values
+	// are written as strings. The other byte values are blobs written in
+	// base64.
+	text bool
+}
+
+// queryColumns returns the columns of rows.
+func queryColumns(rows *sql.Rows) ([]queryColumn, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]queryColumn, len(types))
+	for i, t := range types {
+		// The affinity follows the rules of SQLite from the declared type,
+		// the expressions have none.
+		decl := strings.ToUpper(t.DatabaseTypeName())
+		columns[i] = queryColumn{
+			name: t.Name(),
+			text: !strings.Contains(decl, "INT") &&
+				(strings.Contains(decl, "CHAR") || strings.Contains(decl, "CLOB") || strings.Contains(decl, "TEXT")),
+		}
+	}
+	return columns, nil
+}
+
+// queryEncoder writes the rows of a query as JSON objects.
+type queryEncoder struct {
+	e       *jsontext.Encoder
+	columns []queryColumn
+	values  []any
+	dest    []any
+}
+
+func newQueryEncoder(w io.Writer, columns []queryColumn) *queryEncoder {
+	q := &queryEncoder{
+		// Columns can share a name, such as the columns of a join.
+		e:       jsontext.NewEncoder(w, jsontext.AllowDuplicateNames(true)),
+		columns: columns,
+		values:  make([]any, len(columns)),
+		dest:    make([]any, len(columns)),
+	}
+	for i := range q.values {
+		q.dest[i] = &q.values[i]
+	}
+	return q
+}
+
+// Encode scans the current row of rows and writes it.
+func (q *queryEncoder) Encode(rows *sql.Rows) error {
+	if err := rows.Scan(q.dest...); err != nil {
+		return fmt.Errorf("scan: %v", err)
+	}
+	if err := q.e.WriteToken(jsontext.ObjectStart); err != nil {
+		return err
+	}
+	for i, c := range q.columns {
+		if err := q.e.WriteToken(jsontext.String(c.name)); err != nil {
+			return err
+		}
+		v := q.values[i]
+		if b, ok := v.([]byte); ok && c.text {
+			v = string(b)
+		}
+		if err := jsonv2.MarshalEncode(q.e, v); err != nil {
+			return err
+		}
+	}
+	return q.e.WriteToken(jsontext.ObjectEnd)
+}
+
+// readQuery returns the query of r, from the q parameter or from the body of
+// a POST.
+func readQuery(r *http.Request) (string, error) {
+	query := r.URL.Query().Get("q")
+	if r.Method == http.MethodPost {
+		b, err := io.ReadAll(io.LimitReader(r.Body, maxQuerySize+1))
+		if err != nil {
+			return "", fmt.Errorf("read body: %v", err)
+		}
+		query = string(b)
+	}
+
+	query = strings.TrimSpace(query)
+	switch {
+	case query == "":
+		return "", missingParameter("q")
+	case len(query) > maxQuerySize:
+		return "", &apiError{
+			status:  http.StatusRequestEntityTooLarge,
+			code:    codeInvalidQuery,
+			message: fmt.Sprintf("query exceeds %d bytes", maxQuerySize),
+		}
+	}
+	keyword := strings.ToUpper(query[:len(query)-len(strings.TrimLeftFunc(query, unicode.IsLetter))])
+	if keyword != "SELECT" && keyword != "WITH" {
+		return "", &apiError{
+			status:  http.StatusBadRequest,
+			code:    codeInvalidQuery,
+			message: "query must be a SELECT",
+		}
+	}
+	return query, nil
+}
+
+// queryPages streams the rows of a read-only query as a JSON array of
+// objects, or as NDJSON.
+func (s *Stream) queryPages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query, err := readQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	f, err := parseFormat(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if f != formatJSON && f != formatNDJSON {
+		writeError(w, &apiError{
+			status:  http.StatusBadRequest,
+			code:    codeInvalidParameter,
+			message: fmt.Sprintf("invalid format: %q, expects json or ndjson", f),
+		})
+		return
+	}
+
+	limit, err := s.parseLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	policy, err := parseFlushPolicy(r.URL.Query(), s.flush)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	db, err := s.dbFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.logger.Info("run query", "query", query, "client", r.RemoteAddr)
+	ctx, cancel := context.WithTimeout(r.Context(), s.queryTimeout)
+	defer cancel()
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		writeError(w, s.queryError(ctx, err))
+		return
+	}
+	defer rows.Close()
+	columns, err := queryColumns(rows)
+	if err != nil {
+		writeError(w, s.queryError(ctx, err))
+		return
+	}
+	// Some errors, such as a denied table-valued function or a timeout, are
+	// only returned with the first row: it is read before the response.
+	next := rows.Next()
+	if err := rows.Err(); !next && err != nil {
+		writeError(w, s.queryError(ctx, err))
+		return
+	}
+
+	fw := newFlushWriter(w, policy, s.slowClient)
+	defer s.closeStream(r.Context(), fw)
+	fw.track(streamFromContext(r.Context()))
+
+	w.Header().Set("Content-Type", f.ContentType())
+	w.Header().Add("Vary", "Accept-Encoding")
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		fw.Gzip()
+	}
+
+	q := newQueryEncoder(fw, columns)
+	if f == formatJSON {
+		err = q.e.WriteToken(jsontext.ArrayStart)
+		if err != nil {
+			s.failStream(r.Context(), "fail to encode rows", err, f)
+			return
+		}
+	}
+
+	info := streamFromContext(r.Context())
+	for count := 1; next; count++ {
+		err = q.Encode(rows)
+		if err != nil {
+			s.failStream(r.Context(), "fail to encode rows", err, f)
+			return
+		}
+		info.addPages(1)
+		err = fw.Page()
+		if err != nil {
+			s.failStream(r.Context(), "fail to flush response", err, f)
+			return
+		}
+		if count == limit {
+			break
+		}
+		next = rows.Next()
+	}
+	if err := rows.Err(); err != nil {
+		s.failStream(r.Context(), "fail to query rows", fmt.Errorf("next: %v", err), f)
+		return
+	}
+
+	if f == formatJSON {
+		err = q.e.WriteToken(jsontext.ArrayEnd)
+		if err != nil {
+			s.failStream(r.Context(), "fail to encode rows", err, f)
+			return
+		}
+	}
+}
+
+// queryError returns the error of a query run with ctx: the query is invalid
+// unless it timed out.
+func (s *Stream) queryError(ctx context.Context, err error) *apiError {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &apiError{
+			status:  http.StatusGatewayTimeout,
+			code:    codeQueryTimeout,
+			message: fmt.Sprintf("query exceeds %v", s.queryTimeout),
+		}
+	}
+	return &apiError{status: http.StatusBadRequest, code: codeInvalidQuery, message: err.Error()}
+}
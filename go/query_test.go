@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jsonv2 "github.com/go-json-experiment/json"
+)
+
+func TestQuery(t *testing.T) {
+	s := newTestStream(t)
+
+	// The endpoint is disabled without timeout.
+	resp := httptest.NewRecorder()
+	s.handler().ServeHTTP(resp, httptest.NewRequest("GET", "/query?q=SELECT+1", nil))
+	if resp.Code != 404 {
+		t.Fatalf("unexpected status: %d", resp.Code)
+	}
+
+	s.queryTimeout = time.Second
+	h := s.handler()
+
+	tests := []struct {
+		method   string
+		url      string
+		body     string
+		status   int
+		wantCode string
+		want     string
+	}{
+		{
+			method: "GET", url: "/query?q=SELECT+id,+title+LIKE+'%25'+AS+t,+x'01'+AS+b,+NULL+AS+n,+1.5+AS+f+FROM+pages+LIMIT+2",
+			status: 200, want: `[{"id":1,"t":1,"b":"AQ==","n":null,"f":1.5},{"id":2,"t":1,"b":"AQ==","n":null,"f":1.5}]` + "\n",
+		},
+		{method: "POST", url: "/query?format=ndjson&limit=2", body: "SELECT id\nFROM pages", status: 200, want: "{\"id\":1}\n{\"id\":2}\n"},
+		{method: "GET", url: "/query?q=SELECT+1+AS+a,+2+AS+a", status: 200, want: `[{"a":1,"a":2}]` + "\n"},
+		{method: "GET", url: "/query?q=SELECT+id+FROM+pages+WHERE+id+<+0", status: 200, want: "[]\n"},
+		{method: "GET", url: "/query", status: 400, wantCode: codeMissingParameter},
+		{method: "GET", url: "/query?q=SELECT+1&format=csv", status: 400, wantCode: codeInvalidParameter},
+		{method: "GET", url: "/query?q=DELETE+FROM+pages", status: 400, wantCode: codeInvalidQuery},
+		{method: "GET", url: "/query?q=SELECT+1%3B+DELETE+FROM+pages", status: 400, wantCode: codeInvalidQuery},
+		{method: "GET", url: "/query?q=WITH+x+AS+(SELECT+1)+DELETE+FROM+pages+WHERE+id+IN+x", status: 400, wantCode: codeInvalidQuery},
+		{method: "GET", url: "/query?q=SELECT+*+FROM+pragma_table_info('pages')", status: 400, wantCode: codeInvalidQuery},
+		{method: "GET", url: "/query?q=SELECT+id+FROM+nope", status: 400, wantCode: codeInvalidQuery},
+		{method: "POST", url: "/query", body: "SELECT '" + strings.Repeat("x", maxQuerySize) + "'", status: 413, wantCode: codeInvalidQuery},
+		{method: "HEAD", url: "/query?q=SELECT+1", status: 405, wantCode: codeMethodNotAllowed},
+		{method: "GET", url: "/query?q=SELECT+length(zeroblob(2000000000))", status: 400, wantCode: codeInvalidQuery},
+		{method: "GET", url: "/query?q=SELECT+length(randomblob(100000000))", status: 400, wantCode: codeInvalidQuery},
+		{method: "GET", url: "/query?q=SELECT+length(randomblob(1000000))+AS+n", status: 200, want: `[{"n":1000000}]` + "\n"},
+	}
+	for _, tt := range tests {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+		if resp.Code != tt.status {
+			t.Fatalf("%v %v: unexpected status: expects=%d got=%d %s", tt.method, tt.url, tt.status, resp.Code, resp.Body)
+		}
+		if tt.status != 200 {
+			var r response
+			if err := jsonv2.Unmarshal(resp.Body.Bytes(), &r); err != nil || r.Code != tt.wantCode {
+				t.Fatalf("%v %v: unexpected response: %+v %v", tt.method, tt.url, r, err)
+			}
+			continue
+		}
+		if got := resp.Body.String(); got != tt.want {
+			t.Fatalf("%v %v: unexpected body: %s", tt.method, tt.url, got)
+		}
+	}
+
+	// A query longer than the timeout fails before the response starts.
+	s.queryTimeout = 50 * time.Millisecond
+	resp = httptest.NewRecorder()
+	q := "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c) SELECT count(*) FROM c"
+	h.ServeHTTP(resp, httptest.NewRequest("POST", "/query", strings.NewReader(q)))
+	var r response
+	if err := jsonv2.Unmarshal(resp.Body.Bytes(), &r); err != nil || resp.Code != 504 || r.Code != codeQueryTimeout {
+		t.Fatalf("unexpected response: %d %+v %v", resp.Code, r, err)
+	}
+}
+
+func TestQueryWithRules(t *testing.T) {
+	// The queries would bypass the rules.
+	_, err := NewStream(NewStreamParams{
+		DB:           "stream.db",
+		QueryTimeout: time.Second,
+		Filters:      []PageFilter{KeepNamespaces(mainNamespace)},
+	})
+	if err == nil {
+		t.Fatalf("expects an error")
+	}
+	if got := queryLength(1 << 40); got != math.MaxInt32 {
+		t.Fatalf("unexpected query length: %d", got)
+	}
+}
+
+func TestQueryReadOnly(t *testing.T) {
+	s := newTestStream(t)
+	db := s.collections[defaultCollection].db()
+	ctx := context.Background()
+
+	// The connections are opened by the first query.
+	if db.query != nil {
+		t.Fatalf("unexpected query connections")
+	}
+	conns, err := db.queryDB()
+	if err != nil {
+		t.Fatalf("query db: %v", err)
+	}
+
+	// The connections refuse the writes, also without the authorizer.
+	noAuth, err := sql.Open("sqlite3", queryURI(db.path))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer noAuth.Close()
+	for _, conn := range []*sql.DB{conns, noAuth} {
+		for _, query := range []string{`DELETE FROM pages`, `CREATE TABLE t (x)`} {
+			if _, err := conn.ExecContext(ctx, query); err == nil {
+				t.Fatalf("%v: expects an error", query)
+			}
+		}
+	}
+	if _, err := conns.ExecContext(ctx, `PRAGMA query_only = false`); err == nil {
+		t.Fatalf("pragma: expects an error")
+	}
+
+	var count int
+	if err := conns.QueryRowContext(ctx, `SELECT count(*) FROM pages`).Scan(&count); err != nil || count != 20 {
+		t.Fatalf("unexpected count: %d %v", count, err)
+	}
+}
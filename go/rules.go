@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// redacted replaces the text removed by [Redact].
+const redacted = "[redacted]"
+
+// Redact replaces the matches of re in the titles and texts with
+// "[redacted]".
+func Redact(re *regexp.Regexp) PageTransform {
+	return func(p *Page) {
+		p.Title = re.ReplaceAllLiteralString(p.Title, redacted)
+		p.Text = re.ReplaceAllLiteralString(p.Text, redacted)
+	}
+}
+
+// NormalizeTitle normalizes titles like MediaWiki: underscores are spaces,
+// runs of spaces are collapsed, the title is trimmed and starts with an upper
+// case letter.
+func NormalizeTitle(p *Page) {
+	title := strings.Join(strings.Fields(strings.ReplaceAll(p.Title, "_", " ")), " ")
+	r, size := utf8.DecodeRuneInString(title)
+	if unicode.IsLower(r) {
+		title = string(unicode.ToUpper(r)) + title[size:]
+	}
+	p.Title = title
+}
+
+// TruncateText truncates the texts longer than n bytes, on a rune boundary.
+func TruncateText(n int) PageTransform {
+	return func(p *Page) {
+		if len(p.Text) <= n {
+			return
+		}
+		end := n
+		for end > 0 && !utf8.RuneStart(p.Text[end]) {
+			end--
+		}
+		p.Text = p.Text[:end]
+	}
+}
+
+// mainNamespace is the name of the namespace of the articles, their titles
+// have no prefix.
+const mainNamespace = "main"
+
+// namespaces are the MediaWiki namespaces by lower case name, a title
+// prefixed with another name before a colon is in the main namespace.
+var namespaces = func() map[string]string {
+	names := []string{
+		"Media", "Special", "Talk", "User", "Wikipedia", "File", "MediaWiki",
+		"Template", "Help", "Category", "Portal", "Draft", "TimedText", "Module",
+	}
+	m := make(map[string]string, 2*len(names))
+	for _, name := range names {
+		m[strings.ToLower(name)] = name
+		if name != "Media" && name != "Special" && name != "Talk" {
+			m[strings.ToLower(name)+" talk"] = name + " talk"
+		}
+	}
+	return m
+}()
+
+// pageNamespace returns the namespace of a title, [mainNamespace] for the
+// articles.
+func pageNamespace(title string) string {
+	prefix, _, ok := strings.Cut(title, ":")
+	if !ok {
+		return mainNamespace
+	}
+	name, ok := namespaces[strings.ToLower(strings.TrimSpace(strings.ReplaceAll(prefix, "_", " ")))]
+	if !ok {
+		return mainNamespace
+	}
+	return name
+}
+
+// validNamespace reports whether name is "main" or a MediaWiki namespace.
+func validNamespace(name string) bool {
+	_, ok := namespaces[strings.ToLower(name)]
+	return ok || strings.EqualFold(name, mainNamespace)
+}
+
+// KeepNamespaces keeps the pages of the given namespaces, "main" being the
+// namespace of the articles. The names are case insensitive.
+func KeepNamespaces(names ...string) PageFilter {
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[strings.ToLower(name)] = true
+	}
+	return func(p *Page) bool {
+		return keep[strings.ToLower(pageNamespace(p.Title))]
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	p := Page{Title: "Contact alice@example.com", Text: "mail bob@example.org or alice@example.com"}
+	Redact(regexp.MustCompile(`[a-z]+@[a-z.]+`))(&p)
+	if p.Title != "Contact [redacted]" || p.Text != "mail [redacted] or [redacted]" {
+		t.Fatalf("unexpected page: %+v", p)
+	}
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Go_(programming_language)", "Go (programming language)"},
+		{"  ünicode   title ", "Ünicode title"},
+		{"already Normal", "Already Normal"},
+		{"", ""},
+		{"42 is_a number", "42 is a number"},
+	}
+	for _, tt := range tests {
+		p := Page{Title: tt.in}
+		NormalizeTitle(&p)
+		if p.Title != tt.want {
+			t.Fatalf("%q: unexpected title: %q", tt.in, p.Title)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 5, "hello"},
+		{"hello", 3, "hel"},
+		{"héllo", 2, "h"},
+		{"héllo", 3, "hé"},
+		{"hello", 0, ""},
+	}
+	for _, tt := range tests {
+		p := Page{Text: tt.in}
+		TruncateText(tt.n)(&p)
+		if p.Text != tt.want {
+			t.Fatalf("%q %d: unexpected text: %q", tt.in, tt.n, p.Text)
+		}
+	}
+}
+
+func TestKeepNamespaces(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Go", mainNamespace},
+		{"Star Wars: Episode IV", mainNamespace},
+		{"Category:Programming languages", "Category"},
+		{"category_talk:Programming languages", "Category talk"},
+		{"User talk:Alice", "User talk"},
+		{"Template:Infobox", "Template"},
+	}
+	for _, tt := range tests {
+		if got := pageNamespace(tt.title); got != tt.want {
+			t.Fatalf("%q: unexpected namespace: %q", tt.title, got)
+		}
+	}
+
+	keep := KeepNamespaces("main", "category")
+	for title, want := range map[string]bool{
+		"Go":                             true,
+		"Category:Programming languages": true,
+		"Category talk:Programming":      false,
+		"Template:Infobox":               false,
+	} {
+		if got := keep(&Page{Title: title}); got != want {
+			t.Fatalf("%q: unexpected result: %v", title, got)
+		}
+	}
+
+	if !validNamespace("Main") || !validNamespace("user talk") || validNamespace("Talk talk") {
+		t.Fatalf("unexpected namespace validation")
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxSampleSize is the maximum number of pages of a sample, the IDs of a
+// sample are held in memory.
+const maxSampleSize = 1 << 20
+
+// sampleBatchSize is the number of IDs looked up per query, under the SQLite
+// limit of 32766 parameters.
+const sampleBatchSize = 500
+
+// SamplePageIDs returns the IDs of n pages drawn uniformly without
+// replacement, in ID order. The same seed returns the same sample of the same
+// database.
+//
+// IDs are drawn in the range of the page IDs and kept when the page exists,
+// only the drawn IDs are read. When the IDs are too sparse or the sample
+// covers most of the range, the sample falls back to a scan of the IDs.
+func (db *DB) SamplePageIDs(ctx context.Context, n int, seed uint64) ([]int64, error) {
+	var lo, hi sql.NullInt64
+	err := db.db.QueryRowContext(ctx, `SELECT min(id), max(id) FROM pages`).Scan(&lo, &hi)
+	if err != nil {
+		return nil, fmt.Errorf("range: %v", err)
+	}
+	if !lo.Valid {
+		return []int64{}, nil
+	}
+
+	rng := rand.New(rand.NewPCG(seed, 0))
+	span := hi.Int64 - lo.Int64 + 1
+	if span <= 0 || int64(n) > span/2 {
+		return db.scanSample(ctx, n, rng)
+	}
+
+	// Draws stop at half of the range so that redrawing an ID stays rare,
+	// and at 16 draws per page so that sparse IDs fall back to a scan.
+	maxDraws := min(16*int64(n)+1024, span/2)
+	drawn := make(map[int64]struct{}, n)
+	ids := make([]int64, 0, n)
+	candidates := make([]int64, 0, sampleBatchSize)
+	for len(ids) < n {
+		if int64(len(drawn)) >= maxDraws {
+			return db.scanSample(ctx, n, rng)
+		}
+
+		candidates = candidates[:0]
+		for len(candidates) < sampleBatchSize && int64(len(drawn)) < maxDraws {
+			id := lo.Int64 + rng.Int64N(span)
+			if _, ok := drawn[id]; ok {
+				continue
+			}
+			drawn[id] = struct{}{}
+			candidates = append(candidates, id)
+		}
+
+		exists, err := db.existingIDs(ctx, candidates)
+		if err != nil {
+			return nil, err
+		}
+		// The candidates are kept in draw order for the sample to only
+		// depend on the seed.
+		for _, id := range candidates {
+			if len(ids) == n {
+				break
+			}
+			if exists[id] {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// scanSample draws n IDs with a reservoir over all the IDs.
+func (db *DB) scanSample(ctx context.Context, n int, rng *rand.Rand) ([]int64, error) {
+	rows, err := db.db.QueryContext(ctx, `SELECT id FROM pages ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("query: %v", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, n)
+	for i := int64(0); rows.Next(); i++ {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan: %v", err)
+		}
+		switch {
+		case len(ids) < n:
+			ids = append(ids, id)
+		default:
+			if j := rng.Int64N(i + 1); j < int64(n) {
+				ids[j] = id
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("next: %v", err)
+	}
+
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// existingIDs reports which of ids are the IDs of a page.
+func (db *DB) existingIDs(ctx context.Context, ids []int64) (map[int64]bool, error) {
+	query, args := inQuery(`SELECT id FROM pages WHERE id IN (%s)`, ids)
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query: %v", err)
+	}
+	defer rows.Close()
+
+	exists := make(map[int64]bool, len(ids))
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan: %v", err)
+		}
+		exists[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("next: %v", err)
+	}
+	return exists, nil
+}
+
+// StreamPagesByID streams the pages of ids, in the order of ids when they are
+// sorted. Missing pages are skipped.
+func (db *DB) StreamPagesByID(ctx context.Context, ids []int64) func(func(Page, error) bool) {
+	return func(yield func(Page, error) bool) {
+		for chunk := range slices.Chunk(ids, sampleBatchSize) {
+			query, args := inQuery(`SELECT id, updated_at, title, text FROM pages WHERE id IN (%s) ORDER BY id`, chunk)
+			for p, err := range db.streamPages(ctx, query, args...) {
+				if !yield(p, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// inQuery formats query with one parameter per ID.
+func inQuery(query string, ids []int64) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return fmt.Sprintf(query, strings.Repeat("?,", len(ids)-1)+"?"), args
+}
+
+func (s *Stream) samplePages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+	tmp := q.Get("n")
+	if tmp == "" {
+		writeError(w, missingParameter("n"))
+		return
+	}
+	n, err := strconv.Atoi(tmp)
+	if err != nil || n < 1 {
+		writeError(w, invalidParameter("n", tmp))
+		return
+	}
+	if maxSize := s.maxSampleSize(); n > maxSize {
+		writeError(w, &apiError{
+			status:  http.StatusBadRequest,
+			code:    codeLimitTooLarge,
+			message: fmt.Sprintf("n must be between 1 and %d", maxSize),
+		})
+		return
+	}
+
+	// A sample without seed is random, the seed is returned to reproduce it.
+	seed := rand.Uint64()
+	if tmp := q.Get("seed"); tmp != "" {
+		seed, err = strconv.ParseUint(tmp, 10, 64)
+		if err != nil {
+			writeError(w, invalidParameter("seed", tmp))
+			return
+		}
+	}
+	w.Header().Set("X-Sample-Seed", strconv.FormatUint(seed, 10))
+
+	db, err := s.dbFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ids, err := db.SamplePageIDs(r.Context(), n, seed)
+	if err != nil {
+		s.logger.Error("fail to sample pages", "err", err)
+		writeError(w, err)
+		return
+	}
+
+	pages := NewService(db, s.transforms, s.filters).StreamPagesByID(r.Context(), ids)
+	if isArrow(r) {
+		s.writeArrow(w, r, batchPages(pages, DBSliceBytes))
+		return
+	}
+	s.writePages(w, r, pages)
+}
+
+// maxSampleSize returns the maximum size of a sample, bounded by the
+// maximum limit.
+func (s *Stream) maxSampleSize() int {
+	if s.maxLimit > 0 {
+		return min(s.maxLimit, maxSampleSize)
+	}
+	return maxSampleSize
+}
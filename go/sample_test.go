@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	jsonv2 "github.com/go-json-experiment/json"
+)
+
+func TestSamplePageIDs(t *testing.T) {
+	s := newTestStream(t)
+	db := s.collections[defaultCollection].db()
+	ctx := context.Background()
+
+	// A gap in the IDs biases a sampling of the next ID after a random one.
+	_, err := db.db.ExecContext(ctx, `DELETE FROM pages WHERE id BETWEEN 3 AND 8`)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var all []int64
+	for p, err := range db.StreamPages(ctx, 0) {
+		if err != nil {
+			t.Fatalf("stream pages: %v", err)
+		}
+		all = append(all, p.ID)
+	}
+
+	for _, n := range []int{1, 5, len(all) - 1, len(all), len(all) + 10} {
+		ids, err := db.SamplePageIDs(ctx, n, 42)
+		if err != nil {
+			t.Fatalf("sample %d: %v", n, err)
+		}
+		if len(ids) != min(n, len(all)) {
+			t.Fatalf("sample %d: unexpected size: %d", n, len(ids))
+		}
+		for i, id := range ids {
+			if !slices.Contains(all, id) || (i > 0 && id <= ids[i-1]) {
+				t.Fatalf("sample %d: unexpected IDs: %v", n, ids)
+			}
+		}
+		again, err := db.SamplePageIDs(ctx, n, 42)
+		if err != nil || !reflect.DeepEqual(ids, again) {
+			t.Fatalf("sample %d: not reproducible: %v %v", n, ids, again)
+		}
+	}
+
+	// Each page is drawn with the same probability.
+	const seeds, n = 4000, 3
+	counts := map[int64]int{}
+	for seed := range uint64(seeds) {
+		ids, err := db.SamplePageIDs(ctx, n, seed)
+		if err != nil {
+			t.Fatalf("sample: %v", err)
+		}
+		for _, id := range ids {
+			counts[id]++
+		}
+	}
+	expects := seeds * n / len(all)
+	for _, id := range all {
+		if c := counts[id]; c < expects*3/4 || c > expects*5/4 {
+			t.Fatalf("page %d drawn %d times, expects about %d: %v", id, c, expects, counts)
+		}
+	}
+}
+
+func TestSamplePages(t *testing.T) {
+	s := newTestStream(t)
+	s.maxLimit = 10
+	h := s.handler()
+
+	tests := []struct {
+		url      string
+		status   int
+		wantCode string
+	}{
+		{url: "/pages.sample?n=3&seed=7", status: 200},
+		{url: "/pages.sample", status: 400, wantCode: codeMissingParameter},
+		{url: "/pages.sample?n=0", status: 400, wantCode: codeInvalidParameter},
+		{url: "/pages.sample?n=11", status: 400, wantCode: codeLimitTooLarge},
+		{url: "/pages.sample?n=3&seed=-1", status: 400, wantCode: codeInvalidParameter},
+	}
+	for _, tt := range tests {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest("GET", tt.url, nil))
+		if resp.Code != tt.status {
+			t.Fatalf("%v: unexpected status: expects=%d got=%d", tt.url, tt.status, resp.Code)
+		}
+		if tt.status != 200 {
+			var r response
+			if err := jsonv2.Unmarshal(resp.Body.Bytes(), &r); err != nil || r.Code != tt.wantCode {
+				t.Fatalf("%v: unexpected response: %+v %v", tt.url, r, err)
+			}
+			continue
+		}
+		if got := resp.Header().Get("X-Sample-Seed"); got != "7" {
+			t.Fatalf("%v: unexpected seed: %q", tt.url, got)
+		}
+		pages, err := decodePages(resp.Body.Bytes())
+		if err != nil || len(pages) != 3 {
+			t.Fatalf("%v: unexpected pages: %d %v", tt.url, len(pages), err)
+		}
+	}
+}
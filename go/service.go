@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/y1w5/stream/go/internal/iterx"
+)
+
+// PageTransform changes a page before it is returned to the client.
+type PageTransform func(p *Page)
+
+// PageFilter reports whether a page is returned to the client.
+type PageFilter func(p *Page) bool
+
+// Service stores the business logic of our application. It reads the pages
+// from a [DB] and applies the transforms, then the filters, to each of them.
+// The handlers read the pages through it so that the rules apply to every
+// output format.
+//
+// The limits apply to the pages read from the database, a filter returns
+// fewer pages, except the limit of [Service.SearchPages] which counts the
+// matched pages. The statistics are computed on the database.
+type Service struct {
+	db         *DB
+	transforms []PageTransform
+	filters    []PageFilter
+}
+
+// NewService instanciates a [Service].
+func NewService(db *DB, transforms []PageTransform, filters []PageFilter) *Service {
+	return &Service{db: db, transforms: transforms, filters: filters}
+}
+
+// serviceFor returns the service of the request collection, see
+// [Stream.dbFor].
+func (s *Stream) serviceFor(r *http.Request) (*Service, error) {
+	db, err := s.dbFor(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewService(db, s.transforms, s.filters), nil
+}
+
+// ListPages lists all pages.
+func (svc *Service) ListPages(ctx context.Context, limit int) ([]Page, error) {
+	pages, err := svc.db.ListPages(ctx, limit)
+	if err != nil || svc.empty() {
+		return pages, err
+	}
+
+	// The memory held by the request follows the returned pages.
+	held := pagesMemory(pages)
+	pages = svc.applyAll(pages)
+	streamFromContext(ctx).shrink(held - pagesMemory(pages))
+	return pages, nil
+}
+
+// StreamPages streams pages.
+func (svc *Service) StreamPages(ctx context.Context, limit int) func(func(Page, error) bool) {
+	return svc.applyPages(svc.db.StreamPages(ctx, limit))
+}
+
+// StreamPagesAfter streams pages with an ID greater than after, in ID order.
+func (svc *Service) StreamPagesAfter(ctx context.Context, after int64, limit int) func(func(Page, error) bool) {
+	return svc.applyPages(svc.db.StreamPagesAfter(ctx, after, limit))
+}
+
+// StreamPagesParallel streams pages read by shards, see
+// [DB.StreamPagesParallel].
+func (svc *Service) StreamPagesParallel(ctx context.Context, after int64, limit, shards int, ordered bool) func(func(Page, error) bool) {
+	return svc.applyPages(svc.db.StreamPagesParallel(ctx, after, limit, shards, ordered))
+}
+
+// SearchPages streams pages with a title containing q, in ID order. The
+// title is matched after the rules and the limit counts the returned pages:
+// with transforms, the database cannot match the title and the pages are
+// matched as they are read.
+func (svc *Service) SearchPages(ctx context.Context, q string, after int64, limit int) func(func(Page, error) bool) {
+	if svc.empty() {
+		return svc.db.SearchPages(ctx, q, after, limit)
+	}
+
+	var pages func(func(Page, error) bool)
+	if len(svc.transforms) == 0 {
+		pages = svc.applyPages(svc.db.SearchPages(ctx, q, after, 0))
+	} else {
+		pages = iterx.Filter(svc.applyPages(svc.db.StreamPagesAfter(ctx, after, 0)), func(p Page) bool {
+			return containsFold(p.Title, q)
+		})
+	}
+	if limit < 1 {
+		return pages
+	}
+	return iterx.Take(pages, limit)
+}
+
+// StreamPagesByID streams the pages of ids.
+func (svc *Service) StreamPagesByID(ctx context.Context, ids []int64) func(func(Page, error) bool) {
+	return svc.applyPages(svc.db.StreamPagesByID(ctx, ids))
+}
+
+// StreamPageSlice streams pages into slices.
+func (svc *Service) StreamPageSlice(ctx context.Context, limit int) func(func([]Page, error) bool) {
+	return svc.applySlices(svc.db.StreamPageSlice(ctx, limit))
+}
+
+// StreamPageSliceAfter streams pages with an ID greater than after into
+// slices. The slice is reused between iterations.
+func (svc *Service) StreamPageSliceAfter(ctx context.Context, after int64, limit int) func(func([]Page, error) bool) {
+	return svc.applySlices(svc.db.StreamPageSliceAfter(ctx, after, limit))
+}
+
+// GetPage gets a page by ID, a filtered page is not found.
+func (svc *Service) GetPage(ctx context.Context, id int64) (Page, error) {
+	p, err := svc.db.GetPage(ctx, id)
+	if err != nil {
+		return p, err
+	}
+	if p = svc.transform(p); !svc.keep(p) {
+		return Page{}, ErrPageNotFound
+	}
+	return p, nil
+}
+
+// empty reports whether the service has no rule.
+func (svc *Service) empty() bool {
+	return len(svc.transforms) == 0 && len(svc.filters) == 0
+}
+
+// transform applies the transforms to p.
+func (svc *Service) transform(p Page) Page {
+	for _, transform := range svc.transforms {
+		transform(&p)
+	}
+	return p
+}
+
+// keep reports whether p passes the filters.
+func (svc *Service) keep(p Page) bool {
+	for _, keep := range svc.filters {
+		if !keep(&p) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyAll applies the rules to pages in place and returns the kept pages.
+func (svc *Service) applyAll(pages []Page) []Page {
+	kept := pages[:0]
+	for _, p := range pages {
+		if p = svc.transform(p); svc.keep(p) {
+			kept = append(kept, p)
+		}
+	}
+	clear(pages[len(kept):])
+	return kept
+}
+
+// applyPages applies the rules to a stream of pages.
+func (svc *Service) applyPages(pages func(func(Page, error) bool)) func(func(Page, error) bool) {
+	if svc.empty() {
+		return pages
+	}
+	transformed := iterx.Map(pages, func(p Page) (Page, error) {
+		return svc.transform(p), nil
+	})
+	return iterx.Filter(transformed, svc.keep)
+}
+
+// applySlices applies the rules to a stream of slices in place, the slices
+// left empty are skipped.
+func (svc *Service) applySlices(batches func(func([]Page, error) bool)) func(func([]Page, error) bool) {
+	if svc.empty() {
+		return batches
+	}
+	applied := iterx.Map(batches, func(pages []Page) ([]Page, error) {
+		return svc.applyAll(pages), nil
+	})
+	return iterx.Filter(applied, func(pages []Page) bool {
+		return len(pages) > 0
+	})
+}
+
+// containsFold reports whether s contains substr, ignoring the case of the
+// ASCII letters like the LIKE operator of SQLite.
+func containsFold(s, substr string) bool {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if equalFoldASCII(s[i:i+len(substr)], substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalFoldASCII reports whether a and b, of the same length, are equal
+// ignoring the case of the ASCII letters.
+func equalFoldASCII(a, b string) bool {
+	for i := range len(a) {
+		if lowerASCII(a[i]) != lowerASCII(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
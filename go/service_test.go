@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestServiceRules(t *testing.T) {
+	s := newTestStream(t)
+	db := s.collections[defaultCollection].db()
+	ctx := context.Background()
+
+	// The even pages move to the template namespace.
+	_, err := db.db.ExecContext(ctx, `UPDATE pages SET title = 'Template:' || title WHERE id % 2 = 0`)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	var want []float64
+	for p, err := range db.StreamPages(ctx, 0) {
+		if err != nil {
+			t.Fatalf("stream pages: %v", err)
+		}
+		if p.ID%2 == 1 {
+			want = append(want, float64(p.ID))
+		}
+	}
+
+	s.transforms = []PageTransform{TruncateText(5)}
+	s.filters = []PageFilter{KeepNamespaces(mainNamespace)}
+	h := s.handler()
+
+	tests := []struct {
+		url    string
+		decode func([]byte) ([]map[string]any, error)
+	}{
+		{url: "/pages.list.iter"},
+		{url: "/pages.list.std"},
+		{url: "/pages.list.exp"},
+		{url: "/pages.list.slice"},
+		{url: "/pages.stream"},
+		{url: "/pages.stream.std"},
+		{url: "/pages.stream.slice"},
+		{url: "/pages.stream.marshaler"},
+		{url: "/pages.stream?format=ndjson&shards=3"},
+		{url: "/pages.stream?format=arrow", decode: decodeArrow},
+		{url: "/pages.stream?format=csv", decode: decodeCSV},
+		{url: "/pages.parquet", decode: decodeParquet},
+	}
+	for _, tt := range tests {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest("GET", tt.url, nil))
+		if resp.Code != 200 {
+			t.Fatalf("%v: unexpected status: %d", tt.url, resp.Code)
+		}
+		decode := decodePages
+		if tt.decode != nil {
+			decode = tt.decode
+		}
+		pages, err := decode(resp.Body.Bytes())
+		if err != nil {
+			t.Fatalf("%v: decode: %v", tt.url, err)
+		}
+
+		var ids []float64
+		for _, p := range pages {
+			ids = append(ids, p["ID"].(float64))
+			if text := p["Text"].(string); len(text) > 5 {
+				t.Fatalf("%v: text not truncated: %q", tt.url, text)
+			}
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, want) {
+			t.Fatalf("%v: unexpected pages: expects %v, got %v", tt.url, want, ids)
+		}
+	}
+
+	// A filtered page is not found.
+	for id, status := range map[string]int{"1": 200, "2": 404} {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest("GET", "/pages.get?id="+id, nil))
+		if resp.Code != status {
+			t.Fatalf("page %v: unexpected status: expects=%d got=%d", id, status, resp.Code)
+		}
+	}
+}
+
+func TestServiceApplyAll(t *testing.T) {
+	svc := NewService(nil, []PageTransform{NormalizeTitle}, []PageFilter{KeepNamespaces(mainNamespace)})
+	pages := []Page{{ID: 1, Title: "a_b"}, {ID: 2, Title: "Help:x"}, {ID: 3, Title: "c"}}
+	got := svc.applyAll(pages)
+	if len(got) != 2 || got[0].Title != "A b" || got[1].Title != "C" {
+		t.Fatalf("unexpected pages: %+v", got)
+	}
+	// The references of the dropped pages are cleared.
+	if pages[2] != (Page{}) {
+		t.Fatalf("unexpected tail: %+v", pages[2])
+	}
+}
+
+func TestServiceSearch(t *testing.T) {
+	s := newTestStream(t)
+	db := s.collections[defaultCollection].db()
+	ctx := context.Background()
+	_, err := db.db.ExecContext(ctx, `UPDATE pages SET title = CASE id WHEN 3 THEN 'Secret_plan' WHEN 4 THEN 'Other_plan' ELSE 'Help:plan_' || id END WHERE id <= 4`)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	filters := []PageFilter{KeepNamespaces(mainNamespace)}
+	transforms := []PageTransform{Redact(regexp.MustCompile("Secret")), NormalizeTitle}
+
+	tests := []struct {
+		transforms []PageTransform
+		q          string
+		want       []float64
+	}{
+		// The limit counts the returned pages, after the filters.
+		{q: "plan&limit=1", want: []float64{3}},
+		{q: "plan&limit=2", want: []float64{3, 4}},
+		{q: "Secret", want: []float64{3}},
+		// The titles are matched once redacted, normalized and filtered.
+		{transforms: transforms, q: "secret"},
+		{transforms: transforms, q: "redacted] PLAN", want: []float64{3}},
+		{transforms: transforms, q: "r plan", want: []float64{4}},
+		{transforms: transforms, q: "_plan"},
+		{transforms: transforms, q: "plan&limit=1", want: []float64{3}},
+	}
+	for _, tt := range tests {
+		s.transforms, s.filters = tt.transforms, filters
+		resp := httptest.NewRecorder()
+		s.handler().ServeHTTP(resp, httptest.NewRequest("GET", "/pages.search?q="+strings.ReplaceAll(tt.q, " ", "+"), nil))
+		if resp.Code != 200 {
+			t.Fatalf("%v: unexpected status: %d", tt.q, resp.Code)
+		}
+		pages, err := decodePages(resp.Body.Bytes())
+		if err != nil {
+			t.Fatalf("%v: decode: %v", tt.q, err)
+		}
+		var ids []float64
+		for _, p := range pages {
+			ids = append(ids, p["ID"].(float64))
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Fatalf("%v: unexpected pages: expects %v, got %v", tt.q, tt.want, ids)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	jsonv2 "github.com/go-json-experiment/json"
+)
+
+// TextSizeStats summarizes the size in bytes of the texts of a group of
+// pages. The percentiles use the nearest-rank method.
+type TextSizeStats struct {
+	Total int64 `json:"total"`
+	Min   int64 `json:"min"`
+	P50   int64 `json:"p50"`
+	P90   int64 `json:"p90"`
+	P99   int64 `json:"p99"`
+	Max   int64 `json:"max"`
+}
+
+// MonthStats stores the statistics of the pages updated in a month.
+type MonthStats struct {
+	Month    string        `json:"month"`
+	Pages    int64         `json:"pages"`
+	TextSize TextSizeStats `json:"text_size"`
+}
+
+// PageStats stores the statistics of the pages grouped by month of
+// updated_at.
+type PageStats struct {
+	Pages  int64        `json:"pages"`
+	Months []MonthStats `json:"months"`
+}
+
+// pageStatsQuery ranks the texts by size in each month, the percentiles are
+// the sizes at rank ceil(p*n/100).
+var pageStatsQuery = `WITH sized AS (
+    SELECT
+        strftime('%Y-%m', updated_at) AS month,
+        octet_length(text) AS size,
+        row_number() OVER (PARTITION BY strftime('%Y-%m', updated_at) ORDER BY octet_length(text)) AS rank,
+        count(*) OVER (PARTITION BY strftime('%Y-%m', updated_at)) AS n
+    FROM pages
+)
+SELECT
+    month,
+    count(*),
+    sum(size),
+    min(size),
+    max(CASE WHEN rank = (50 * n + 99) / 100 THEN size END),
+    max(CASE WHEN rank = (90 * n + 99) / 100 THEN size END),
+    max(CASE WHEN rank = (99 * n + 99) / 100 THEN size END),
+    max(size)
+FROM sized
+GROUP BY month
+ORDER BY month`
+
+// PageStats returns the page counts and text sizes by month. The result is
+// cached for the lifetime of db.
+func (db *DB) PageStats(ctx context.Context) (PageStats, error) {
+	return cached(ctx, &db.cache, "stats", func(ctx context.Context) (PageStats, error) {
+		rows, err := db.db.QueryContext(ctx, pageStatsQuery)
+		if err != nil {
+			return PageStats{}, fmt.Errorf("query: %v", err)
+		}
+		defer rows.Close()
+
+		stats := PageStats{Months: []MonthStats{}}
+		for rows.Next() {
+			var m MonthStats
+			s := &m.TextSize
+			err := rows.Scan(&m.Month, &m.Pages, &s.Total, &s.Min, &s.P50, &s.P90, &s.P99, &s.Max)
+			if err != nil {
+				return PageStats{}, fmt.Errorf("scan: %v", err)
+			}
+			stats.Pages += m.Pages
+			stats.Months = append(stats.Months, m)
+		}
+		if err := rows.Err(); err != nil {
+			return PageStats{}, fmt.Errorf("scan: %v", err)
+		}
+		return stats, nil
+	})
+}
+
+// histogramFields maps the fields of [DB.Histogram] to their SQL expression.
+var histogramFields = map[string]string{
+	"text_length":  "octet_length(text)",
+	"title_length": "octet_length(title)",
+}
+
+// maxHistogramBuckets is the maximum number of buckets of a histogram.
+const maxHistogramBuckets = 1000
+
+// Bucket is a bucket of a [Histogram] holding the values in [Start, End).
+type Bucket struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Count int64 `json:"count"`
+}
+
+// Histogram stores the distribution of a field in buckets of equal width.
+type Histogram struct {
+	Field   string   `json:"field"`
+	Min     int64    `json:"min"`
+	Max     int64    `json:"max"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// histogramQuery assigns each value to the bucket floor((x-lo)*n/(hi-lo+1)).
+var histogramQuery = `WITH v AS (SELECT %s AS x FROM pages),
+r AS (SELECT min(x) AS lo, max(x) AS hi FROM v)
+SELECT lo, hi, (x - lo) * ? / (hi - lo + 1) AS bucket, count(*)
+FROM v, r
+GROUP BY bucket
+ORDER BY bucket`
+
+// Histogram returns the distribution of field in at most buckets buckets of
+// equal width, fewer when the range of the values is smaller. The result is
+// cached for the lifetime of db.
+func (db *DB) Histogram(ctx context.Context, field string, buckets int) (Histogram, error) {
+	expr, ok := histogramFields[field]
+	if !ok {
+		return Histogram{}, fmt.Errorf("unknown field %q", field)
+	}
+	key := "histogram:" + field + ":" + strconv.Itoa(buckets)
+	return cached(ctx, &db.cache, key, func(ctx context.Context) (Histogram, error) {
+		rows, err := db.db.QueryContext(ctx, fmt.Sprintf(histogramQuery, expr), buckets)
+		if err != nil {
+			return Histogram{}, fmt.Errorf("query: %v", err)
+		}
+		defer rows.Close()
+
+		h := Histogram{Field: field, Buckets: []Bucket{}}
+		counts := map[int]int64{}
+		for rows.Next() {
+			var bucket int
+			var count int64
+			if err := rows.Scan(&h.Min, &h.Max, &bucket, &count); err != nil {
+				return Histogram{}, fmt.Errorf("scan: %v", err)
+			}
+			counts[bucket] = count
+		}
+		if err := rows.Err(); err != nil {
+			return Histogram{}, fmt.Errorf("scan: %v", err)
+		}
+		if len(counts) == 0 {
+			return h, nil
+		}
+
+		// Bucket i starts at the smallest x with floor((x-lo)*n/span) >= i.
+		span := h.Max - h.Min + 1
+		n := int64(buckets)
+		start := func(i int64) int64 { return h.Min + (i*span+n-1)/n }
+		for i := range n {
+			b := Bucket{Start: start(i), End: start(i + 1), Count: counts[int(i)]}
+			if b.Start == b.End {
+				continue // narrower than a unit
+			}
+			h.Buckets = append(h.Buckets, b)
+		}
+		return h, nil
+	})
+}
+
+// statsCache caches the aggregates of a database. A reloaded database is a
+// new [DB] with an empty cache, so the aggregates are computed once per
+// version of the database file.
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]*statsEntry
+}
+
+type statsEntry struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+// cached returns the value of key, calling compute once for concurrent
+// callers. compute is not cancelled with ctx so that a computation started by
+// a client that went away still fills the cache. Errors are not cached.
+func cached[T any](ctx context.Context, c *statsCache, key string, compute func(context.Context) (T, error)) (T, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		if c.entries == nil {
+			c.entries = map[string]*statsEntry{}
+		}
+		e = &statsEntry{done: make(chan struct{})}
+		c.entries[key] = e
+		go func() {
+			defer close(e.done)
+			e.value, e.err = compute(context.WithoutCancel(ctx))
+			if e.err != nil {
+				c.mu.Lock()
+				delete(c.entries, key)
+				c.mu.Unlock()
+			}
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+	if e.err != nil {
+		var zero T
+		return zero, e.err
+	}
+	return e.value.(T), nil
+}
+
+func (s *Stream) pageStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	db, err := s.dbFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	stats, err := db.PageStats(r.Context())
+	if err != nil {
+		s.logger.Error("fail to compute stats", "err", err)
+		writeError(w, err)
+		return
+	}
+
+	err = jsonv2.MarshalWrite(w, response{OK: true, Payload: stats})
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+}
+
+func (s *Stream) pageHistogram(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+	field := q.Get("field")
+	if field == "" {
+		writeError(w, missingParameter("field"))
+		return
+	}
+	if _, ok := histogramFields[field]; !ok {
+		writeError(w, invalidParameter("field", field))
+		return
+	}
+
+	buckets := 10
+	if tmp := q.Get("buckets"); tmp != "" {
+		n, err := strconv.Atoi(tmp)
+		if err != nil || n < 1 || n > maxHistogramBuckets {
+			writeError(w, invalidParameter("buckets", tmp))
+			return
+		}
+		buckets = n
+	}
+
+	db, err := s.dbFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h, err := db.Histogram(r.Context(), field, buckets)
+	if err != nil {
+		s.logger.Error("fail to compute histogram", "err", err)
+		writeError(w, err)
+		return
+	}
+
+	err = jsonv2.MarshalWrite(w, response{OK: true, Payload: h})
+	if err != nil {
+		s.logger.Error("fail to encode JSON", "err", err)
+		return
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestPageStats(t *testing.T) {
+	s := newTestStream(t)
+	db := s.collections[defaultCollection].db()
+	ctx := context.Background()
+
+	// The expected statistics are computed from the pages.
+	sizes := map[string][]int64{}
+	var months []string
+	for p, err := range db.StreamPages(ctx, 0) {
+		if err != nil {
+			t.Fatalf("stream pages: %v", err)
+		}
+		m := p.UpdatedAt.Format("2006-01")
+		if _, ok := sizes[m]; !ok {
+			months = append(months, m)
+		}
+		sizes[m] = append(sizes[m], int64(len(p.Text)))
+	}
+	slices.Sort(months)
+	want := PageStats{Months: []MonthStats{}}
+	for _, m := range months {
+		v := sizes[m]
+		slices.Sort(v)
+		n := len(v)
+		rank := func(p int) int64 { return v[(p*n+99)/100-1] }
+		var total int64
+		for _, size := range v {
+			total += size
+		}
+		want.Pages += int64(n)
+		want.Months = append(want.Months, MonthStats{
+			Month:    m,
+			Pages:    int64(n),
+			TextSize: TextSizeStats{Total: total, Min: v[0], P50: rank(50), P90: rank(90), P99: rank(99), Max: v[n-1]},
+		})
+	}
+
+	got, err := db.PageStats(ctx)
+	if err != nil {
+		t.Fatalf("page stats: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected stats:\nexpects=%+v\ngot=%+v", want, got)
+	}
+
+	// The statistics are cached until the database is reloaded.
+	_, err = db.db.ExecContext(ctx, `INSERT INTO pages (updated_at, title, text) VALUES ('2001-01-01 00:00:00', 'x', 'y')`)
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	got, err = db.PageStats(ctx)
+	if err != nil {
+		t.Fatalf("page stats: %v", err)
+	}
+	if got.Pages != want.Pages {
+		t.Fatalf("expects cached stats: expects=%d got=%d", want.Pages, got.Pages)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	s := newTestStream(t)
+	db := s.collections[defaultCollection].db()
+	ctx := context.Background()
+
+	for _, buckets := range []int{1, 3, 7, 1000} {
+		h, err := db.Histogram(ctx, "text_length", buckets)
+		if err != nil {
+			t.Fatalf("histogram: %v", err)
+		}
+		if len(h.Buckets) == 0 || len(h.Buckets) > buckets {
+			t.Fatalf("%d buckets: unexpected bucket count: %d", buckets, len(h.Buckets))
+		}
+		if h.Buckets[0].Start != h.Min || h.Buckets[len(h.Buckets)-1].End != h.Max+1 {
+			t.Fatalf("%d buckets: unexpected range: %+v", buckets, h)
+		}
+
+		var count int64
+		for i, b := range h.Buckets {
+			if i > 0 && b.Start != h.Buckets[i-1].End {
+				t.Fatalf("%d buckets: bucket %d is not contiguous: %+v", buckets, i, h.Buckets)
+			}
+			count += b.Count
+		}
+		// The buckets are shared with the cache.
+		rest := slices.Clone(h.Buckets)
+		for p, err := range db.StreamPages(ctx, 0) {
+			if err != nil {
+				t.Fatalf("stream pages: %v", err)
+			}
+			size := int64(len(p.Text))
+			i := slices.IndexFunc(rest, func(b Bucket) bool { return size >= b.Start && size < b.End })
+			if i < 0 {
+				t.Fatalf("%d buckets: no bucket for size %d", buckets, size)
+			}
+			rest[i].Count--
+			count--
+		}
+		if count != 0 || slices.ContainsFunc(rest, func(b Bucket) bool { return b.Count != 0 }) {
+			t.Fatalf("%d buckets: counts differ from the pages", buckets)
+		}
+	}
+
+	if _, err := db.Histogram(ctx, "id", 10); err == nil {
+		t.Fatalf("expects an error for an unknown field")
+	}
+}
@@ -2,17 +2,19 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	jsonv2 "github.com/go-json-experiment/json"
 	"github.com/go-json-experiment/json/jsontext"
 	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/grpc"
 
 	"github.com/y1w5/stream/go/internal/middleware"
 )
@@ -20,8 +22,36 @@ import (
 // Stream is the main application. It stores and links all the components.
 type Stream struct {
 	server *http.Server
-	db     *DB
+	admin  *http.Server
+	grpc   *grpc.Server
 	logger *slog.Logger
+	flush  FlushPolicy
+
+	// collections maps the collection names to their database, the default
+	// collection is served at the root routes.
+	collections map[string]*collection
+	watchEvery  time.Duration
+	stopWatch   context.CancelFunc
+
+	slowClient SlowClientPolicy
+	slowAborts atomic.Int64
+
+	streams streamRegistry
+
+	defaultLimit int
+	maxLimit     int
+
+	shards int
+
+	requestMemory int64
+	queryTimeout  time.Duration
+
+	// transforms and filters are the rules of the [Service] of the
+	// requests.
+	transforms []PageTransform
+	filters    []PageFilter
+
+	grpcBind string
 
 	errChan chan error
 }
@@ -29,49 +59,198 @@ type Stream struct {
 // NewStreamParams stores required parameters for [NewStream].
 type NewStreamParams struct {
 	Bind   string
-	DB     string
 	Logger *slog.Logger
+
+	// DB is the path of the database served at the root routes, empty
+	// disables them.
+	DB string
+	// Collections maps collection names to the path of their database,
+	// served under /{collection}/. Each collection has its own connection
+	// pool.
+	Collections map[string]string
+
+	// Watch is the interval at which the database files are checked, a
+	// replaced file is reloaded. 0 disables the watcher, [Stream.Reload]
+	// reloads the files on demand.
+	Watch time.Duration
+
+	// AdminBind is the address of the admin listener, empty disables it.
+	AdminBind string
+	// GRPCBind is the address of the gRPC listener, empty disables it.
+	GRPCBind string
+
+	// Flush is the default flush policy of the stream handlers. It can be
+	// overridden per request with the flush_pages, flush_bytes and flush_ms
+	// query parameters.
+	Flush FlushPolicy
+
+	// SlowClient protects the stream handlers from slow clients.
+	SlowClient SlowClientPolicy
+
+	// DefaultLimit is the number of pages returned when the client does not
+	// set a limit, 0 returns all the pages up to MaxLimit.
+	DefaultLimit int
+	// MaxLimit is the maximum number of pages a client can request, 0
+	// disables the maximum.
+	MaxLimit int
+
+	// Shards is the default number of connections reading /pages.stream in
+	// parallel, 0 or 1 reads with a single cursor. It can be overridden per
+	// request with the shards query parameter.
+	Shards int
+
+	// RequestMemory is the approximate memory a request can hold, 0
+	// disables the budget. The list handlers buffering all the pages switch
+	// to streaming or fail with a 507 when they exceed it.
+	RequestMemory int64
+
+	// QueryTimeout is the timeout of the read-only SQL queries of /query, 0
+	// disables the endpoint. The queries are not subject to Transforms and
+	// Filters.
+	QueryTimeout time.Duration
+
+	// Transforms and Filters are applied to every page returned by the
+	// HTTP and gRPC handlers, see [Service].
+	Transforms []PageTransform
+	Filters    []PageFilter
 }
 
 // NewStream instanciates a [Stream].
 func NewStream(arg NewStreamParams) (*Stream, error) {
-	db, err := NewDB(arg.DB)
+	if arg.DefaultLimit < 0 || arg.MaxLimit < 0 {
+		return nil, fmt.Errorf("negative limit")
+	}
+	if arg.MaxLimit > 0 && arg.DefaultLimit > arg.MaxLimit {
+		return nil, fmt.Errorf("default limit %d exceeds max limit %d", arg.DefaultLimit, arg.MaxLimit)
+	}
+	if arg.Shards < 0 || arg.Shards > maxShards {
+		return nil, fmt.Errorf("shards must be between 0 and %d", maxShards)
+	}
+	if arg.RequestMemory < 0 {
+		return nil, fmt.Errorf("negative request memory")
+	}
+	if arg.QueryTimeout < 0 {
+		return nil, fmt.Errorf("negative query timeout")
+	}
+	// The queries read the pages as stored, a redacted text would be one
+	// SELECT away.
+	if arg.QueryTimeout > 0 && (len(arg.Transforms) > 0 || len(arg.Filters) > 0) {
+		return nil, fmt.Errorf("query timeout cannot be set with transforms or filters")
+	}
+
+	collections, err := openCollections(arg.DB, arg.Collections, queryLength(arg.RequestMemory))
 	if err != nil {
 		return nil, fmt.Errorf("new db: %v", err)
 	}
 
-	return &Stream{
-		db:      db,
-		server:  &http.Server{Addr: arg.Bind},
-		logger:  arg.Logger,
-		errChan: make(chan error, 1),
-	}, nil
+	s := &Stream{
+		collections: collections,
+		watchEvery:  arg.Watch,
+		server:      newHTTPServer(arg.Bind),
+		logger:      arg.Logger,
+		flush:       arg.Flush,
+		errChan:     make(chan error, 3),
+
+		slowClient: arg.SlowClient,
+
+		defaultLimit: arg.DefaultLimit,
+		maxLimit:     arg.MaxLimit,
+
+		shards: arg.Shards,
+
+		requestMemory: arg.RequestMemory,
+		queryTimeout:  arg.QueryTimeout,
+
+		transforms: arg.Transforms,
+		filters:    arg.Filters,
+	}
+	if arg.AdminBind != "" {
+		s.admin = newHTTPServer(arg.AdminBind)
+	}
+	if arg.GRPCBind != "" {
+		s.grpc = newGRPCServer(s)
+		s.grpcBind = arg.GRPCBind
+	}
+	return s, nil
+}
+
+// newHTTPServer creates the HTTP server. It has no write timeout as it would
+// cut long streams, the stream handlers set a deadline on each chunk instead.
+func newHTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
 }
 
+// pageMethods are the HTTP methods allowed on the page routes.
+var pageMethods = []string{http.MethodGet, http.MethodHead}
+
+// streamMethods are the HTTP methods allowed on the routes reading pages by
+// the thousands. A HEAD would read and encode them for nothing.
+var streamMethods = []string{http.MethodGet}
+
 // ListenAndServe listens and serves HTTP requests.
 func (s *Stream) ListenAndServe() error {
+	s.server.Handler = middleware.Logger(s.logger, s.handler())
+
+	if s.watchEvery > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopWatch = cancel
+		go s.watch(ctx, s.watchEvery)
+	}
+
+	s.serve(s.server)
+	if s.admin != nil {
+		s.admin.Handler = middleware.Logger(s.logger, s.adminHandler())
+		s.serve(s.admin)
+	}
+	if s.grpc != nil {
+		s.serveGRPC()
+	}
+
+	select {
+	case err := <-s.errChan:
+		return err
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+// handler returns the handler of the page routes. Each route is served for
+// the default collection and under /{collection}/.
+func (s *Stream) handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", notFoundHandler)
-	mux.HandleFunc("/pages.list", s.listPages)
-	mux.HandleFunc("/pages.stream", s.streamPages)
-	s.server.Handler = middleware.Logger(s.logger, mux)
+	for _, prefix := range []string{"", "/{collection}"} {
+		s.handleEncoders(mux, prefix+"/pages.list", listEncoders)
+		s.handleEncoders(mux, prefix+"/pages.stream", streamEncoders)
+		mux.HandleFunc(prefix+"/pages.get", allowMethods(s.withCollection(s.getPage), pageMethods...))
+		mux.HandleFunc(prefix+"/pages.parquet", allowMethods(s.withCollection(s.track(s.parquetPages)), streamMethods...))
+		mux.HandleFunc(prefix+"/pages.search", allowMethods(s.withCollection(s.track(s.searchPages)), streamMethods...))
+		mux.HandleFunc(prefix+"/pages.sample", allowMethods(s.withCollection(s.track(s.samplePages)), streamMethods...))
+		mux.HandleFunc(prefix+"/pages.stats", allowMethods(s.withCollection(s.pageStats), pageMethods...))
+		mux.HandleFunc(prefix+"/pages.histogram", allowMethods(s.withCollection(s.pageHistogram), pageMethods...))
+		if s.queryTimeout > 0 {
+			mux.HandleFunc(prefix+"/query", allowMethods(s.withCollection(s.track(s.queryPages)), queryMethods...))
+		}
+	}
+	return mux
+}
 
+// serve serves srv in the background, reporting its error on s.errChan.
+func (s *Stream) serve(srv *http.Server) {
 	go func() {
-		s.logger.Info("listening on " + s.server.Addr)
-		err := s.server.ListenAndServe()
+		s.logger.Info("listening on " + srv.Addr)
+		err := srv.ListenAndServe()
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.errChan <- fmt.Errorf("listen: %v", err)
 			return
 		}
 		s.errChan <- nil
 	}()
-
-	select {
-	case err := <-s.errChan:
-		return err
-	case <-time.After(time.Second):
-		return nil
-	}
 }
 
 // Close closes allocated ressources. It waits for 5 seconds for the running
@@ -82,14 +261,34 @@ func (s *Stream) Close() error {
 
 	s.logger.Info("closing server & db")
 
+	if s.stopWatch != nil {
+		s.stopWatch()
+	}
+
+	servers := []*http.Server{s.server}
+	if s.admin != nil {
+		servers = append(servers, s.admin)
+	}
+
 	var errs []error
-	if err := s.server.Shutdown(ctx); err != nil {
-		errs = append(errs, fmt.Errorf("server: %v", err))
+	if s.grpc != nil {
+		s.stopGRPC(ctx)
 	}
-	if err := <-s.errChan; err != nil {
-		errs = append(errs, fmt.Errorf("server: %v", err))
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("server: %v", err))
+		}
+	}
+	n := len(servers)
+	if s.grpc != nil {
+		n++
+	}
+	for range n {
+		if err := <-s.errChan; err != nil {
+			errs = append(errs, fmt.Errorf("server: %v", err))
+		}
 	}
-	if err := s.db.Close(); err != nil {
+	if err := closeCollections(s.collections); err != nil {
 		errs = append(errs, fmt.Errorf("db: %v", err))
 	}
 
@@ -102,35 +301,90 @@ func (s *Stream) Close() error {
 type response struct {
 	OK      bool   `json:"ok"`
 	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
 	Payload any    `json:"payload,omitempty"`
 }
 
 func notFoundHandler(w http.ResponseWriter, r *http.Request) {
 	status := http.StatusNotFound
-	http.Error(w, http.StatusText(status), status)
+	writeError(w, &apiError{status: status, code: codeNotFound, message: http.StatusText(status)})
 }
 
 func (s *Stream) listPages(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	limit, err := parseLimit(r)
+	limit, err := s.parseLimit(r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(response{Error: err.Error()})
+		writeError(w, err)
 		return
 	}
 
-	var pages []Page
-	for p, err := range s.db.StreamPages(r.Context(), limit) {
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeList(w, r, countPages(r.Context(), svc.StreamPages(r.Context(), limit)))
+}
+
+// writeList buffers pages and writes them as a JSON array. When the buffered
+// pages exceed the memory budget of the request, the buffered pages are
+// written and the remaining pages are streamed, the response is the same.
+func (s *Stream) writeList(w http.ResponseWriter, r *http.Request, pages func(func(Page, error) bool)) {
+	info := streamFromContext(r.Context())
+	var held int64
+	defer func() { info.shrink(held) }()
+
+	e := jsontext.NewEncoder(w)
+	var buf []Page
+	streaming := false
+	for p, err := range pages {
 		if err != nil {
 			s.logger.Error("fail to stream pages", "err", err)
 			return
 		}
-		pages = append(pages, p)
+		if streaming {
+			err = jsonv2.MarshalEncode(e, p)
+			if err != nil {
+				s.logger.Error("fail to encode JSON", "err", err)
+				return
+			}
+			continue
+		}
+
+		buf = append(buf, p)
+		n := pageMemory(&p)
+		held += n
+		if info.grow(n) == nil {
+			continue
+		}
+
+		s.logger.Warn("downgrade request to streaming", "route", r.URL.Path, "pages", len(buf), "budget", info.budget)
+		streaming = true
+		err = e.WriteToken(jsontext.ArrayStart)
+		for _, p := range buf {
+			if err != nil {
+				break
+			}
+			err = jsonv2.MarshalEncode(e, p)
+		}
+		if err != nil {
+			s.logger.Error("fail to encode JSON", "err", err)
+			return
+		}
+		buf = nil
+		info.shrink(held)
+		held = 0
 	}
 
-	e := jsontext.NewEncoder(w)
-	err = jsonv2.MarshalEncode(e, pages)
+	if streaming {
+		err := e.WriteToken(jsontext.ArrayEnd)
+		if err != nil {
+			s.logger.Error("fail to encode JSON", "err", err)
+		}
+		return
+	}
+	err := jsonv2.MarshalEncode(e, buf)
 	if err != nil {
 		s.logger.Error("fail to encode JSON", "err", err)
 		return
@@ -140,47 +394,351 @@ func (s *Stream) listPages(w http.ResponseWriter, r *http.Request) {
 func (s *Stream) streamPages(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	limit, err := parseLimit(r)
+	limit, err := s.parseLimit(r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(response{Error: err.Error()})
+		writeError(w, err)
 		return
 	}
 
-	e := jsontext.NewEncoder(w)
-	err = e.WriteToken(jsontext.ArrayStart)
+	after, err := parseAfter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	shards, err := s.parseShards(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	unordered, err := parseUnordered(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if shards > 1 {
+		// The clients resume a stream after the ID of their last page, an
+		// unordered stream is flagged for them to refuse it. A limit forces
+		// the ID order.
+		ordered := !unordered || limit > 0
+		if !ordered {
+			w.Header().Set(pageOrderHeader, "unordered")
+		}
+		pages := svc.StreamPagesParallel(r.Context(), after, limit, shards, ordered)
+		if isArrow(r) {
+			s.writeArrow(w, r, batchPages(pages, DBSliceBytes))
+			return
+		}
+		s.writePages(w, r, pages)
+		return
+	}
+
+	if isArrow(r) {
+		s.writeArrow(w, r, svc.StreamPageSliceAfter(r.Context(), after, limit))
+		return
+	}
+	s.writePages(w, r, svc.StreamPagesAfter(r.Context(), after, limit))
+}
+
+func (s *Stream) searchPages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		writeError(w, missingParameter("q"))
+		return
+	}
+
+	limit, err := s.parseLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	after, err := parseAfter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pages := svc.SearchPages(r.Context(), q, after, limit)
+	if isArrow(r) {
+		s.writeArrow(w, r, batchPages(pages, DBSliceBytes))
+		return
+	}
+	s.writePages(w, r, pages)
+}
+
+func (s *Stream) getPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	svc, err := s.serviceFor(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	p, err := svc.GetPage(r.Context(), id)
+	if errors.Is(err, ErrPageNotFound) {
+		writeError(w, &apiError{status: http.StatusNotFound, code: codeNotFound, message: err.Error()})
+		return
+	}
+	if err != nil {
+		s.logger.Error("fail to get page", "err", err)
+		writeError(w, err)
+		return
+	}
+
+	err = jsonv2.MarshalWrite(w, response{OK: true, Payload: p})
 	if err != nil {
 		s.logger.Error("fail to encode JSON", "err", err)
 		return
 	}
+}
 
-	for p, err := range s.db.StreamPages(r.Context(), limit) {
+// writePages streams pages in the format requested by the client, applying
+// the flush policy and the compression.
+func (s *Stream) writePages(w http.ResponseWriter, r *http.Request, pages func(func(Page, error) bool)) {
+	format, err := parseFormat(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	policy, err := parseFlushPolicy(r.URL.Query(), s.flush)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var csvOpts csvOptions
+	if format == formatCSV || format == formatTSV {
+		csvOpts, err = parseCSVOptions(r.URL.Query(), format)
 		if err != nil {
-			s.logger.Error("fail to stream pages", "err", err)
+			writeError(w, err)
+			return
+		}
+	}
+
+	fw := newFlushWriter(w, policy, s.slowClient)
+	defer s.closeStream(r.Context(), fw)
+	fw.track(streamFromContext(r.Context()))
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Add("Vary", "Accept-Encoding")
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		fw.Gzip()
+	}
+
+	pages = countPages(r.Context(), pages)
+	e := newPageEncoder(fw, format, csvOpts)
+	err = e.Begin()
+	if err != nil {
+		s.failStream(r.Context(), "fail to encode pages", err, format)
+		return
+	}
+
+	for p, err := range pages {
+		if err != nil {
+			s.failStream(r.Context(), "fail to stream pages", err, format)
 			return
 		}
-		err = jsonv2.MarshalEncode(e, p)
+		err = e.Encode(&p)
 		if err != nil {
-			s.logger.Error("fail to encode JSON", "err", err)
+			s.failStream(r.Context(), "fail to encode pages", err, format)
+			return
+		}
+		err = fw.Page()
+		if err != nil {
+			s.failStream(r.Context(), "fail to flush response", err, format)
 			return
 		}
 	}
 
-	err = e.WriteToken(jsontext.ArrayEnd)
+	err = e.End()
 	if err != nil {
-		s.logger.Error("fail to encode JSON", "err", err)
+		s.failStream(r.Context(), "fail to encode pages", err, format)
 		return
 	}
 }
 
-func parseLimit(r *http.Request) (int, error) {
-	tmp := r.URL.Query().Get("limit")
-	if tmp == "" {
-		return 0, nil
+// pageEncoder writes a stream of pages in a format.
+type pageEncoder interface {
+	// Begin writes the start of the stream.
+	Begin() error
+	// Encode writes a page.
+	Encode(p *Page) error
+	// End writes the end of the stream.
+	End() error
+}
+
+// newPageEncoder returns the encoder of the format f writing to w. csv only
+// applies to the CSV and TSV formats.
+func newPageEncoder(w io.Writer, f format, csv csvOptions) pageEncoder {
+	switch f {
+	case formatCSV, formatTSV:
+		return newCSVEncoder(w, csv)
+	case formatMsgPack:
+		return &msgpackEncoder{w: w}
+	case formatCBOR:
+		return &cborEncoder{w: w}
+	default:
+		return &jsonEncoder{w: w, array: f == formatJSON}
 	}
-	limit, err := strconv.Atoi(tmp)
-	if err != nil {
-		return 0, nil
+}
+
+// jsonEncoder streams pages as a JSON array, or as NDJSON without array.
+//
+// Each page is marshaled as a top-level value: a [jsontext.Encoder] keeps the
+// nested values in its buffer, the pages of an array would not reach the
+// [flushWriter] before it is flushed.
+type jsonEncoder struct {
+	w     io.Writer
+	array bool
+	pages int
+}
+
+// Begin implements [pageEncoder].
+func (e *jsonEncoder) Begin() error {
+	if !e.array {
+		return nil
+	}
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+// Encode implements [pageEncoder].
+func (e *jsonEncoder) Encode(p *Page) error {
+	if e.array && e.pages > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.pages++
+	if err := jsonv2.MarshalWrite(e.w, p); err != nil {
+		return err
+	}
+	if e.array {
+		return nil
+	}
+	_, err := io.WriteString(e.w, "\n")
+	return err
+}
+
+// End implements [pageEncoder].
+func (e *jsonEncoder) End() error {
+	if !e.array {
+		return nil
+	}
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+// failStream logs the failure of a stream and aborts the connection of slow
+// clients, of cancelled requests and of failed streams other than JSON and
+// CBOR arrays. Unlike an array, a NDJSON, MessagePack, CSV or Arrow stream
+// cut between two records is valid, aborting the connection lets clients
+// detect the truncation.
+func (s *Stream) failStream(ctx context.Context, msg string, err error, f format) {
+	if ctx.Err() != nil {
+		s.logger.Warn("abort cancelled stream", "err", err)
+		panic(http.ErrAbortHandler)
+	}
+	if isSlowClient(err) {
+		count := s.slowAborts.Add(1)
+		s.logger.Warn("abort slow client", "err", err, "aborted", count)
+		panic(http.ErrAbortHandler)
+	}
+
+	s.logger.Error(msg, "err", err)
+	if f != formatJSON && f != formatCBOR {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// closeStream closes fw once the stream is written. The error of the final
+// flush can no longer be sent to the client, it is logged.
+func (s *Stream) closeStream(ctx context.Context, fw *flushWriter) {
+	err := fw.Close()
+	switch {
+	case err == nil:
+	case ctx.Err() != nil || isSlowClient(err):
+		s.logger.Warn("fail to close aborted stream", "err", err)
+	default:
+		s.logger.Error("fail to close stream", "err", err)
+	}
+}
+
+// format is the encoding of a page stream.
+type format string
+
+const (
+	formatJSON    format = "json"
+	formatNDJSON  format = "ndjson"
+	formatArrow   format = "arrow"
+	formatMsgPack format = "msgpack"
+	formatCBOR    format = "cbor"
+	formatCSV     format = "csv"
+	formatTSV     format = "tsv"
+	// formatParquet is only served by /pages.parquet.
+	formatParquet format = "parquet"
+)
+
+// ContentType returns the MIME type of the format.
+func (f format) ContentType() string {
+	switch f {
+	case formatNDJSON:
+		return "application/x-ndjson"
+	case formatArrow:
+		return "application/vnd.apache.arrow.stream"
+	case formatMsgPack:
+		return "application/msgpack"
+	case formatCBOR:
+		return "application/cbor"
+	case formatCSV:
+		return "text/csv; charset=utf-8"
+	case formatTSV:
+		return "text/tab-separated-values; charset=utf-8"
+	case formatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "application/json"
+	}
+}
+
+// isArrow reports whether the client requests an Arrow stream. An invalid
+// format is reported by the JSON writer.
+func isArrow(r *http.Request) bool {
+	f, err := parseFormat(r)
+	return err == nil && f == formatArrow
+}
+
+// acceptsGzip reports whether the client accepts gzip compressed responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(enc, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
 	}
-	return limit, nil
+	return false
 }
@@ -2,16 +2,11 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
-	"time"
-
-	jsonv2 "github.com/go-json-experiment/json"
-	"github.com/go-json-experiment/json/jsontext"
 )
 
 const streamStdBodyLen = 578_257_270
@@ -19,15 +14,27 @@ const streamExpBodyLen = 557_377_380
 const streamBufferSize = 600_000_000
 
 func BenchmarkStream(b *testing.B) {
+	path := testDBPath(b)
 	s, err := NewStream(NewStreamParams{
 		Bind:   "localhost:8080",
-		DB:     dbPath,
+		DB:     path,
 		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
 	})
 	if err != nil {
 		b.Fatalf("fail to create stream: %v", err)
 	}
 
+	// The body lengths of the synthetic database are measured with the
+	// reference handlers.
+	stdBodyLen, expBodyLen := streamStdBodyLen, streamExpBodyLen
+	if path != dbPath {
+		stdBodyLen = recordBodyLen(s.listPagesStd, "/")
+		expBodyLen = recordBodyLen(s.listPagesExp, "/")
+	}
+	// The binary formats are only measured.
+	msgpackBodyLen := recordBodyLen(s.streamPages, "/?format=msgpack")
+	cborBodyLen := recordBodyLen(s.streamPages, "/?format=cbor")
+
 	benchs := []struct {
 		url             string
 		method          http.HandlerFunc
@@ -36,42 +43,52 @@ func BenchmarkStream(b *testing.B) {
 		{
 			url:             "/test/pages.listStd",
 			method:          s.listPagesStd,
-			expectedBodyLen: streamStdBodyLen,
+			expectedBodyLen: stdBodyLen,
 		},
 		{
 			url:             "/test/pages.streamStd",
 			method:          s.streamPagesStd,
-			expectedBodyLen: 578257268,
+			expectedBodyLen: stdBodyLen - 2, // no brackets, one line break per page
 		},
 		{
 			url:             "/test/pages.listExp",
 			method:          s.listPagesExp,
-			expectedBodyLen: streamExpBodyLen,
+			expectedBodyLen: expBodyLen,
 		},
 		{
 			url:             "/test/pages.listSlice",
 			method:          s.listPagesSlice,
-			expectedBodyLen: streamExpBodyLen,
+			expectedBodyLen: expBodyLen,
 		},
 		{
 			url:             "/test/pages.streamSlice",
 			method:          s.streamPagesSlice,
-			expectedBodyLen: streamExpBodyLen,
+			expectedBodyLen: expBodyLen,
 		},
 		{
 			url:             "/pages.list",
 			method:          s.listPages,
-			expectedBodyLen: streamExpBodyLen,
+			expectedBodyLen: expBodyLen,
 		},
 		{
 			url:             "/pages.stream",
 			method:          s.streamPages,
-			expectedBodyLen: streamExpBodyLen,
+			expectedBodyLen: expBodyLen,
 		},
 		{
 			url:             "/pages.streamWithMarshaler",
 			method:          s.streamPagesWithMarshaler,
-			expectedBodyLen: streamExpBodyLen,
+			expectedBodyLen: expBodyLen,
+		},
+		{
+			url:             "/pages.stream?format=msgpack",
+			method:          s.streamPages,
+			expectedBodyLen: msgpackBodyLen,
+		},
+		{
+			url:             "/pages.stream?format=cbor",
+			method:          s.streamPages,
+			expectedBodyLen: cborBodyLen,
 		},
 	}
 
@@ -101,200 +118,8 @@ func BenchmarkStream(b *testing.B) {
 	}
 }
 
-// listPagesStd lists all pages from the database and write the JSON using
-// `encoding/json` from the standard library.
-func (s *Stream) listPagesStd(w http.ResponseWriter, r *http.Request) {
-	var pages []Page
-	w.Header().Set("Content-Type", "application/json")
-
-	limit, err := parseLimit(r)
-	if err != nil {
-		goto encode_err
-	}
-
-	pages, err = s.db.ListPages(r.Context(), limit)
-	if err != nil {
-		s.logger.Error("fail to execute handler", "err", err)
-		goto encode_err
-	}
-
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(pages)
-	return
-
-encode_err:
-	w.WriteHeader(http.StatusInternalServerError)
-	_ = json.NewEncoder(w).Encode(response{Error: err.Error()})
-}
-
-// streamPagesStd streams pages from the database and write the JSON using
-// `encoding/json` from the standard library.
-func (s *Stream) streamPagesStd(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	limit, err := parseLimit(r)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(response{Error: err.Error()})
-		return
-	}
-
-	e := json.NewEncoder(w)
-	for p, err := range s.db.StreamPages(r.Context(), limit) {
-		if err != nil {
-			s.logger.Error("fail to stream pages", "err", err)
-			return
-		}
-		err = e.Encode(p)
-		if err != nil {
-			s.logger.Error("fail to encode JSON", "err", err)
-			return
-		}
-	}
-}
-
-// listPagesExp lists all pages from the database and writes the JSON using
-// the experimental `encoding/json/v2`.
-func (s *Stream) listPagesExp(w http.ResponseWriter, r *http.Request) {
-	var pages []Page
-	w.Header().Set("Content-Type", "application/json")
-
-	limit, err := parseLimit(r)
-	if err != nil {
-		goto encode_err
-	}
-
-	pages, err = s.db.ListPages(r.Context(), limit)
-	if err != nil {
-		s.logger.Error("fail to execute handler", "err", err)
-		goto encode_err
-	}
-
-	w.WriteHeader(http.StatusOK)
-	_ = jsonv2.MarshalEncode(jsontext.NewEncoder(w), pages)
-	return
-
-encode_err:
-	w.WriteHeader(http.StatusInternalServerError)
-	_ = json.NewEncoder(w).Encode(response{Error: err.Error()})
-}
-
-// listPagesSlice lists pages as slice of 65Mo, aggregates them and write the
-// JSON using the experimental `encoding/json/v2`.
-func (s *Stream) listPagesSlice(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	limit, err := parseLimit(r)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(response{Error: err.Error()})
-		return
-	}
-
-	var pages []Page
-	for tmps, err := range s.db.StreamPageSlice(r.Context(), limit) {
-		if err != nil {
-			s.logger.Error("fail to stream pages", "err", err)
-			return
-		}
-		pages = append(pages, tmps...)
-	}
-
-	e := jsontext.NewEncoder(w)
-	err = jsonv2.MarshalEncode(e, pages)
-	if err != nil {
-		s.logger.Error("fail to encode JSON", "err", err)
-		return
-	}
-}
-
-// streamPagesSlice streams pages as slice of 65Mo and write the JSON using
-// the experimental `encoding/json/v2`.
-func (s *Stream) streamPagesSlice(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	limit, err := parseLimit(r)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(response{Error: err.Error()})
-		return
-	}
-
-	e := jsontext.NewEncoder(w)
-	err = e.WriteToken(jsontext.ArrayStart)
-	if err != nil {
-		s.logger.Error("fail to encode JSON", "err", err)
-		return
-	}
-
-	for pages, err := range s.db.StreamPageSlice(r.Context(), limit) {
-		if err != nil {
-			s.logger.Error("fail to stream pages", "err", err)
-			return
-		}
-		for _, p := range pages {
-			err = jsonv2.MarshalEncode(e, p)
-			if err != nil {
-				s.logger.Error("fail to encode JSON", "err", err)
-				return
-			}
-		}
-	}
-
-	err = e.WriteToken(jsontext.ArrayEnd)
-	if err != nil {
-		s.logger.Error("fail to encode JSON", "err", err)
-		return
-	}
-}
-
-func marshalPage(e *jsontext.Encoder, p *Page, opts jsonv2.Options) error {
-	_ = e.WriteToken(jsontext.ObjectStart)
-	_ = e.WriteToken(jsontext.String("ID"))
-	_ = e.WriteToken(jsontext.Int(p.ID))
-	_ = e.WriteToken(jsontext.String("UpdatedAt"))
-	_ = e.WriteToken(jsontext.String(p.UpdatedAt.Format(time.RFC3339)))
-	_ = e.WriteToken(jsontext.String("Title"))
-	_ = e.WriteToken(jsontext.String(p.Title))
-	_ = e.WriteToken(jsontext.String("Text"))
-	_ = e.WriteToken(jsontext.String(p.Text))
-	_ = e.WriteToken(jsontext.ObjectEnd)
-	return nil
-}
-
-func (s *Stream) streamPagesWithMarshaler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	limit, err := parseLimit(r)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(response{Error: err.Error()})
-		return
-	}
-
-	e := jsontext.NewEncoder(w)
-	err = e.WriteToken(jsontext.ArrayStart)
-	if err != nil {
-		s.logger.Error("fail to encode JSON", "err", err)
-		return
-	}
-
-	opts := jsonv2.WithMarshalers(jsonv2.MarshalFuncV2(marshalPage))
-	for p, err := range s.db.StreamPages(r.Context(), limit) {
-		if err != nil {
-			s.logger.Error("fail to stream pages", "err", err)
-			return
-		}
-		err = jsonv2.MarshalEncode(e, &p, opts)
-		if err != nil {
-			s.logger.Error("fail to encode JSON", "err", err)
-			return
-		}
-	}
-
-	err = e.WriteToken(jsontext.ArrayEnd)
-	if err != nil {
-		s.logger.Error("fail to encode JSON", "err", err)
-		return
-	}
+func recordBodyLen(h http.HandlerFunc, url string) int {
+	resp := httptest.NewRecorder()
+	h(resp, httptest.NewRequest("GET", url, nil))
+	return resp.Body.Len()
 }
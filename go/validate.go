@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Error codes returned in the code field of the error responses.
+const (
+	codeInvalidParameter = "invalid_parameter"
+	codeMissingParameter = "missing_parameter"
+	codeLimitTooLarge    = "limit_too_large"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeNotFound         = "not_found"
+	codeInternal         = "internal_error"
+	codeMemoryBudget     = "memory_budget_exceeded"
+	codeInvalidQuery     = "invalid_query"
+	codeQueryTimeout     = "query_timeout"
+)
+
+// apiError is an error returned to the client.
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+// Error implements [error].
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func invalidParameter(name, value string) *apiError {
+	return &apiError{
+		status:  http.StatusBadRequest,
+		code:    codeInvalidParameter,
+		message: fmt.Sprintf("invalid %v: %q", name, value),
+	}
+}
+
+func missingParameter(name string) *apiError {
+	return &apiError{
+		status:  http.StatusBadRequest,
+		code:    codeMissingParameter,
+		message: "missing " + name,
+	}
+}
+
+// writeError writes err in the response envelope. Errors other than
+// [apiError] are reported as internal errors.
+func writeError(w http.ResponseWriter, err error) {
+	var e *apiError
+	if !errors.As(err, &e) {
+		e = &apiError{
+			status:  http.StatusInternalServerError,
+			code:    codeInternal,
+			message: err.Error(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	_ = json.NewEncoder(w).Encode(response{Error: e.message, Code: e.code})
+}
+
+// allowMethods rejects the requests with a method other than methods.
+func allowMethods(next http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allow := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(methods, r.Method) {
+			w.Header().Set("Allow", allow)
+			writeError(w, &apiError{
+				status:  http.StatusMethodNotAllowed,
+				code:    codeMethodNotAllowed,
+				message: fmt.Sprintf("method %v not allowed, expects %v", r.Method, allow),
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// parseLimit reads the limit query parameter. A missing limit defaults to
+// s.defaultLimit and a limit over s.maxLimit is rejected, 0 disables both.
+func (s *Stream) parseLimit(r *http.Request) (int, error) {
+	tmp := r.URL.Query().Get("limit")
+	if tmp == "" {
+		if s.defaultLimit == 0 {
+			return s.maxLimit, nil
+		}
+		return s.defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(tmp)
+	if err != nil || limit < 0 {
+		return 0, invalidParameter("limit", tmp)
+	}
+	if s.maxLimit > 0 && (limit == 0 || limit > s.maxLimit) {
+		return 0, &apiError{
+			status:  http.StatusBadRequest,
+			code:    codeLimitTooLarge,
+			message: fmt.Sprintf("limit must be between 1 and %d", s.maxLimit),
+		}
+	}
+	return limit, nil
+}
+
+// parseShards reads the number of shards of a parallel read, falling back on
+// the default of the server.
+func (s *Stream) parseShards(r *http.Request) (int, error) {
+	tmp := r.URL.Query().Get("shards")
+	if tmp == "" {
+		return s.shards, nil
+	}
+	shards, err := strconv.Atoi(tmp)
+	if err != nil || shards < 1 || shards > maxShards {
+		return 0, invalidParameter("shards", tmp)
+	}
+	return shards, nil
+}
+
+func parseAfter(r *http.Request) (int64, error) {
+	tmp := r.URL.Query().Get("after")
+	if tmp == "" {
+		return 0, nil
+	}
+	after, err := strconv.ParseInt(tmp, 10, 64)
+	if err != nil || after < 0 {
+		return 0, invalidParameter("after", tmp)
+	}
+	return after, nil
+}
+
+func parseID(r *http.Request) (int64, error) {
+	tmp := r.URL.Query().Get("id")
+	if tmp == "" {
+		return 0, missingParameter("id")
+	}
+	id, err := strconv.ParseInt(tmp, 10, 64)
+	if err != nil || id < 1 {
+		return 0, invalidParameter("id", tmp)
+	}
+	return id, nil
+}
+
+// pageOrderHeader is set to "unordered" on the responses of unordered
+// parallel reads.
+const pageOrderHeader = "X-Page-Order"
+
+// parseUnordered reads the unordered query parameter, only valid with the
+// NDJSON format.
+func parseUnordered(r *http.Request) (bool, error) {
+	tmp := r.URL.Query().Get("unordered")
+	if tmp == "" {
+		return false, nil
+	}
+	unordered, err := strconv.ParseBool(tmp)
+	if err != nil {
+		return false, invalidParameter("unordered", tmp)
+	}
+	if f, _ := parseFormat(r); unordered && f != formatNDJSON {
+		err := invalidParameter("unordered", tmp)
+		err.message += ", expects the ndjson format"
+		return false, err
+	}
+	return unordered, nil
+}
+
+// parseFormat reads the format from the format query parameter, falling back
+// on the Accept header.
+func parseFormat(r *http.Request) (format, error) {
+	switch tmp := r.URL.Query().Get("format"); tmp {
+	case "":
+	case string(formatJSON), string(formatNDJSON), string(formatArrow), string(formatMsgPack), string(formatCBOR),
+		string(formatCSV), string(formatTSV):
+		return format(tmp), nil
+	default:
+		return "", invalidParameter("format", tmp)
+	}
+
+	return acceptedFormat(r.Header.Get("Accept")), nil
+}
+
+// acceptFormats are the formats selected by the Accept header.
+var acceptFormats = []format{formatJSON, formatNDJSON, formatArrow, formatMsgPack, formatCBOR, formatCSV, formatTSV}
+
+// acceptedFormat returns the format of the media range of accept with the
+// highest quality, the first one on a tie. The ranges with a zero quality
+// are refused and the wildcards select JSON.
+func acceptedFormat(accept string) format {
+	best, bestQ := formatJSON, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if tmp, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(tmp, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		for _, f := range acceptFormats {
+			if t, _, _ := mime.ParseMediaType(f.ContentType()); t == mediaType {
+				best, bestQ = f, q
+				break
+			}
+		}
+	}
+	return best
+}
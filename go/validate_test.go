@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsonv2 "github.com/go-json-experiment/json"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultLimit int
+		maxLimit     int
+		query        string
+		want         int
+		wantCode     string
+	}{
+		{name: "unset", query: "", want: 0},
+		{name: "valid", query: "limit=10", want: 10},
+		{name: "not a number", query: "limit=abc", wantCode: codeInvalidParameter},
+		{name: "negative", query: "limit=-1", wantCode: codeInvalidParameter},
+		{name: "default", defaultLimit: 100, query: "", want: 100},
+		{name: "default to max", maxLimit: 1000, query: "", want: 1000},
+		{name: "under max", maxLimit: 1000, query: "limit=1000", want: 1000},
+		{name: "over max", maxLimit: 1000, query: "limit=1001", wantCode: codeLimitTooLarge},
+		{name: "everything over max", maxLimit: 1000, query: "limit=0", wantCode: codeLimitTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Stream{defaultLimit: tt.defaultLimit, maxLimit: tt.maxLimit}
+			got, err := s.parseLimit(httptest.NewRequest("GET", "/?"+tt.query, nil))
+			if tt.wantCode != "" {
+				e, ok := err.(*apiError)
+				if !ok || e.code != tt.wantCode {
+					t.Fatalf("unexpected error: expects code=%v got=%v", tt.wantCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("unexpected limit: expects=%d got=%d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		query  string
+		accept string
+		want   format
+	}{
+		{want: formatJSON},
+		{accept: "*/*", want: formatJSON},
+		{query: "format=msgpack", want: formatMsgPack},
+		{query: "format=cbor", accept: "application/x-ndjson", want: formatCBOR},
+		{accept: "application/msgpack", want: formatMsgPack},
+		{accept: "application/cbor, application/json;q=0.5", want: formatCBOR},
+		{accept: "application/vnd.apache.arrow.stream", want: formatArrow},
+		{accept: "text/csv", want: formatCSV},
+		{accept: "application/cbor;q=0", want: formatJSON},
+		{accept: "application/cbor; q=0, application/msgpack", want: formatMsgPack},
+		{accept: "application/msgpack;q=0.2, application/x-ndjson;q=0.8", want: formatNDJSON},
+		{accept: "application/json, application/cbor;q=0.9", want: formatJSON},
+		{accept: "application/cbor-seq, text/csvx", want: formatJSON},
+		{accept: "application/cbor;q=abc, text/tab-separated-values", want: formatTSV},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/?"+tt.query, nil)
+		r.Header.Set("Accept", tt.accept)
+		got, err := parseFormat(r)
+		if err != nil {
+			t.Fatalf("%q %q: unexpected error: %v", tt.query, tt.accept, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%q %q: unexpected format: expects=%v got=%v", tt.query, tt.accept, tt.want, got)
+		}
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	s := newTestStream(t)
+	mux := http.NewServeMux()
+	s.handleEncoders(mux, "/pages.stream", streamEncoders)
+	mux.HandleFunc("/pages.get", allowMethods(s.getPage, pageMethods...))
+
+	tests := []struct {
+		method     string
+		url        string
+		wantStatus int
+		wantCode   string
+	}{
+		{method: "GET", url: "/pages.stream?limit=abc", wantStatus: 400, wantCode: codeInvalidParameter},
+		{method: "GET", url: "/pages.stream.std?limit=abc", wantStatus: 400, wantCode: codeInvalidParameter},
+		{method: "GET", url: "/pages.stream?after=x", wantStatus: 400, wantCode: codeInvalidParameter},
+		{method: "GET", url: "/pages.stream?format=xml", wantStatus: 400, wantCode: codeInvalidParameter},
+		{method: "GET", url: "/pages.stream?flush_ms=-1", wantStatus: 400, wantCode: codeInvalidParameter},
+		{method: "GET", url: "/pages.stream?format=csv&columns=foo", wantStatus: 400, wantCode: codeInvalidParameter},
+		{method: "GET", url: "/pages.stream?shards=0", wantStatus: 400, wantCode: codeInvalidParameter},
+		{method: "GET", url: "/pages.stream?encoder=x", wantStatus: 400, wantCode: codeInvalidParameter},
+		{method: "POST", url: "/pages.stream", wantStatus: 405, wantCode: codeMethodNotAllowed},
+		{method: "HEAD", url: "/pages.stream", wantStatus: 405, wantCode: codeMethodNotAllowed},
+		{method: "HEAD", url: "/pages.stream.std", wantStatus: 405, wantCode: codeMethodNotAllowed},
+		{method: "GET", url: "/pages.get", wantStatus: 400, wantCode: codeMissingParameter},
+		{method: "GET", url: "/pages.get?id=999999", wantStatus: 404, wantCode: codeNotFound},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, nil))
+		if w.Code != tt.wantStatus {
+			t.Fatalf("%v %v: unexpected status: expects=%d got=%d", tt.method, tt.url, tt.wantStatus, w.Code)
+		}
+
+		var resp response
+		if err := jsonv2.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%v %v: decode: %v", tt.method, tt.url, err)
+		}
+		if resp.OK || resp.Code != tt.wantCode || resp.Error == "" {
+			t.Fatalf("%v %v: unexpected response: %+v", tt.method, tt.url, resp)
+		}
+	}
+}